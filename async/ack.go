@@ -0,0 +1,93 @@
+package async
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+type outcome uint8
+
+const (
+	outcomePending outcome = iota
+	outcomeAck
+	outcomeNack
+)
+
+// tagTracker 记录一个信道上尚未确认的delivery tag，
+// 以便用multiple=true批量确认时不会误伤仍在处理中的消息
+type tagTracker struct {
+	mutex   sync.Mutex
+	ack     amqp.Acknowledger
+	requeue bool
+	tags    map[uint64]outcome
+}
+
+func newTagTracker(requeue bool) *tagTracker {
+	return &tagTracker{
+		requeue: requeue,
+		tags:    make(map[uint64]outcome),
+	}
+}
+
+// add 按接收顺序登记delivery，同一信道的delivery共用同一个Acknowledger
+func (t *tagTracker) add(d amqp.Delivery) {
+	t.mutex.Lock()
+	t.ack = d.Acknowledger
+	t.tags[d.DeliveryTag] = outcomePending
+	t.mutex.Unlock()
+}
+
+// forget 移除已单独确认的tag
+func (t *tagTracker) forget(tag uint64) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.tags, tag)
+	return t.flush()
+}
+
+// settle 记录tag的处理结果，并尽可能批量确认
+func (t *tagTracker) settle(tag uint64, o outcome) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.tags[tag]; !ok {
+		return nil
+	}
+	t.tags[tag] = o
+	return t.flush()
+}
+
+// flush 从最小的tag开始，把结果相同的连续tag合并为一次multiple确认，
+// 遇到仍在处理中的tag即停止，因为multiple会覆盖所有不大于该tag的消息
+func (t *tagTracker) flush() error {
+	tags := make([]uint64, 0, len(t.tags))
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	for i := 0; i < len(tags); {
+		o := t.tags[tags[i]]
+		if o == outcomePending {
+			return nil
+		}
+		j := i
+		for j+1 < len(tags) && t.tags[tags[j+1]] == o {
+			j++
+		}
+		var err error
+		if o == outcomeAck {
+			err = t.ack.Ack(tags[j], true)
+		} else {
+			err = t.ack.Nack(tags[j], true, t.requeue)
+		}
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags[i : j+1] {
+			delete(t.tags, tag)
+		}
+		i = j + 1
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type ackCall struct {
+	method   string
+	tag      uint64
+	multiple bool
+	requeue  bool
+}
+
+type recordAck struct {
+	calls []ackCall
+}
+
+func (r *recordAck) Ack(tag uint64, multiple bool) error {
+	r.calls = append(r.calls, ackCall{method: "ack", tag: tag, multiple: multiple})
+	return nil
+}
+
+func (r *recordAck) Nack(tag uint64, multiple bool, requeue bool) error {
+	r.calls = append(r.calls, ackCall{method: "nack", tag: tag, multiple: multiple, requeue: requeue})
+	return nil
+}
+
+func (r *recordAck) Reject(tag uint64, requeue bool) error {
+	r.calls = append(r.calls, ackCall{method: "reject", tag: tag, requeue: requeue})
+	return nil
+}
+
+func TestTagTrackerNackMultiple(t *testing.T) {
+	type step struct {
+		tag uint64
+		o   outcome // outcomePending 表示单独确认后forget
+	}
+	tests := []struct {
+		name  string
+		tags  []uint64
+		steps []step
+		want  []ackCall
+	}{
+		{
+			name:  "wait for smaller pending tag",
+			tags:  []uint64{1, 2, 3},
+			steps: []step{{tag: 2, o: outcomeNack}},
+			want:  nil,
+		},
+		{
+			name:  "merge contiguous failures",
+			tags:  []uint64{1, 2, 3},
+			steps: []step{{tag: 2, o: outcomeNack}, {tag: 1, o: outcomeNack}},
+			want:  []ackCall{{method: "nack", tag: 2, multiple: true, requeue: true}},
+		},
+		{
+			name:  "stop at pending tag",
+			tags:  []uint64{1, 2, 3, 4},
+			steps: []step{{tag: 1, o: outcomeNack}, {tag: 3, o: outcomeNack}},
+			want:  []ackCall{{method: "nack", tag: 1, multiple: true, requeue: true}},
+		},
+		{
+			name: "skip tags acked individually",
+			tags: []uint64{1, 2, 3},
+			steps: []step{
+				{tag: 3, o: outcomeNack},
+				{tag: 2, o: outcomePending},
+				{tag: 1, o: outcomeNack},
+			},
+			want: []ackCall{{method: "nack", tag: 3, multiple: true, requeue: true}},
+		},
+		{
+			name: "split runs of different outcome",
+			tags: []uint64{1, 2, 3},
+			steps: []step{
+				{tag: 2, o: outcomeNack},
+				{tag: 3, o: outcomeAck},
+				{tag: 1, o: outcomeAck},
+			},
+			want: []ackCall{
+				{method: "ack", tag: 1, multiple: true},
+				{method: "nack", tag: 2, multiple: true, requeue: true},
+				{method: "ack", tag: 3, multiple: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &recordAck{}
+			tracker := newTagTracker(true)
+			for _, tag := range tt.tags {
+				tracker.add(amqp.Delivery{Acknowledger: ack, DeliveryTag: tag})
+			}
+			for _, s := range tt.steps {
+				if s.o == outcomePending {
+					assert.NoError(t, tracker.forget(s.tag))
+					continue
+				}
+				assert.NoError(t, tracker.settle(s.tag, s.o))
+			}
+			assert.Equal(t, tt.want, ack.calls)
+		})
+	}
+}
+
+func TestTaskConsumerNack(t *testing.T) {
+	ack := &recordAck{}
+	tracker := newTagTracker(false)
+	for tag := uint64(1); tag <= 2; tag++ {
+		tracker.add(amqp.Delivery{Acknowledger: ack, DeliveryTag: tag})
+	}
+	tc := &taskConsumer{}
+	ctx := context.Background()
+	assert.NoError(t, tc.nack(ctx, amqp.Delivery{Acknowledger: ack, DeliveryTag: 2}, tracker, RejectHandlerError))
+	tc.NackMultiple = true
+	assert.NoError(t, tc.nack(ctx, amqp.Delivery{Acknowledger: ack, DeliveryTag: 1}, tracker, RejectHandlerError))
+	assert.Equal(t, []ackCall{
+		{method: "reject", tag: 2},
+		{method: "nack", tag: 1, multiple: true},
+	}, ack.calls)
+}
@@ -0,0 +1,179 @@
+// Package asynctest provides an in-memory async.Channel to test the task handlers without rabbitmq.
+package asynctest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/streadway/amqp"
+
+	"github.com/crochee/lirity/mq"
+)
+
+// queueSize is the max number of messages not consumed in a queue
+const queueSize = 1024
+
+// ErrClosed is returned by the Channel after Close.
+var ErrClosed = errors.New("asynctest: channel closed")
+
+// ErrQueueFull is returned by Publish if the queue has queueSize messages not consumed.
+var ErrQueueFull = errors.New("asynctest: queue full")
+
+const (
+	MethodAck    = "ack"
+	MethodNack   = "nack"
+	MethodReject = "reject"
+)
+
+// Call is an ack, nack or reject of a delivery.
+type Call struct {
+	Method   string
+	Tag      uint64
+	Multiple bool
+	Requeue  bool
+}
+
+// Channel is an in-memory async.Channel, which is also the amqp.Acknowledger of its deliveries.
+// The messages are routed to the queue named by the routing key, the exchange is ignored.
+type Channel struct {
+	mutex  sync.Mutex
+	queues map[string]chan amqp.Delivery
+	tag    uint64
+	calls  []Call
+	closed bool
+}
+
+// NewChannel creates a Channel.
+func NewChannel() *Channel {
+	return &Channel{queues: make(map[string]chan amqp.Delivery)}
+}
+
+func (c *Channel) queue(name string) chan amqp.Delivery {
+	q, ok := c.queues[name]
+	if !ok {
+		q = make(chan amqp.Delivery, queueSize)
+		c.queues[name] = q
+	}
+	return q
+}
+
+// Publish routes msg to the queue named by key.
+func (c *Channel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+	c.tag++
+	d := delivery(msg, c.tag, c)
+	d.Exchange, d.RoutingKey = exchange, key
+	select {
+	case c.queue(key) <- d:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Consume returns the deliveries of the queue, all the consumers of a queue share its messages.
+func (c *Channel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil, ErrClosed
+	}
+	return c.queue(queue), nil
+}
+
+// Close closes the deliveries of all the queues,
+// cancel the context of the consumer first, or Subscribe keeps consuming again.
+func (c *Channel) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	for _, q := range c.queues {
+		close(q)
+	}
+	return nil
+}
+
+func (c *Channel) record(call Call) error {
+	c.mutex.Lock()
+	c.calls = append(c.calls, call)
+	c.mutex.Unlock()
+	return nil
+}
+
+func (c *Channel) Ack(tag uint64, multiple bool) error {
+	return c.record(Call{Method: MethodAck, Tag: tag, Multiple: multiple})
+}
+
+func (c *Channel) Nack(tag uint64, multiple bool, requeue bool) error {
+	return c.record(Call{Method: MethodNack, Tag: tag, Multiple: multiple, Requeue: requeue})
+}
+
+func (c *Channel) Reject(tag uint64, requeue bool) error {
+	return c.record(Call{Method: MethodReject, Tag: tag, Requeue: requeue})
+}
+
+// Calls returns the recorded ack, nack and reject calls in order.
+func (c *Channel) Calls() []Call {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	calls := make([]Call, len(c.calls))
+	copy(calls, c.calls)
+	return calls
+}
+
+// Handler is implemented by the consumer of async.NewTaskConsumer.
+type Handler interface {
+	HandleDelivery(ctx context.Context, d amqp.Delivery) error
+}
+
+// Deliver marshals payload, the JSON of async.Param, like async.TaskProducer does,
+// feeds it through the Handler and returns how the delivery is acked.
+func Deliver(tb testing.TB, h Handler, payload []byte) Call {
+	tb.Helper()
+	msg, err := mq.DefaultMarshal{}.Marshal(message.NewMessage(watermill.NewUUID(), payload))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	c := NewChannel()
+	if err = h.HandleDelivery(context.Background(), delivery(msg, 1, c)); err != nil {
+		tb.Fatal(err)
+	}
+	calls := c.Calls()
+	if len(calls) != 1 {
+		tb.Fatalf("want 1 ack call, got %v", calls)
+	}
+	return calls[0]
+}
+
+func delivery(msg amqp.Publishing, tag uint64, ack amqp.Acknowledger) amqp.Delivery {
+	return amqp.Delivery{
+		Acknowledger:    ack,
+		Headers:         msg.Headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    msg.DeliveryMode,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		Expiration:      msg.Expiration,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		DeliveryTag:     tag,
+		Body:            msg.Body,
+	}
+}
@@ -0,0 +1,75 @@
+package asynctest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/async"
+)
+
+type okTask struct{}
+
+func (o okTask) SafeCopy() async.Executor { return o }
+
+func (o okTask) ID() string { return "" }
+
+func (o okTask) Run(ctx context.Context, data []byte) error { return nil }
+
+type failTask struct{}
+
+func (f failTask) SafeCopy() async.Executor { return f }
+
+func (f failTask) ID() string { return "" }
+
+func (f failTask) Run(ctx context.Context, data []byte) error { return errors.New("failed") }
+
+func TestDeliver(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []func(*async.ConsumerOption)
+		payload string
+		want    Call
+	}{
+		{name: "ack", payload: `{"name":"asynctest.okTask"}`, want: Call{Method: MethodAck, Tag: 1}},
+		{name: "invalid json", payload: `{`, want: Call{Method: MethodReject, Tag: 1}},
+		{name: "validate failed", payload: `{}`, want: Call{Method: MethodReject, Tag: 1}},
+		{
+			name:    "requeue",
+			opts:    []func(*async.ConsumerOption){func(o *async.ConsumerOption) { o.Requeue = true }},
+			payload: `{"name":"asynctest.failTask"}`,
+			want:    Call{Method: MethodReject, Tag: 1, Requeue: true},
+		},
+		{
+			name: "nack multiple",
+			opts: []func(*async.ConsumerOption){func(o *async.ConsumerOption) {
+				o.NackMultiple = true
+			}},
+			payload: `{"name":"asynctest.failTask"}`,
+			want:    Call{Method: MethodNack, Tag: 1, Multiple: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := async.NewTaskConsumer(context.Background(), tt.opts...)
+			assert.NoError(t, tc.Register(okTask{}, failTask{}))
+			assert.Equal(t, tt.want, Deliver(t, tc, []byte(tt.payload)))
+		})
+	}
+}
+
+func TestChannel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := NewChannel()
+	tc := async.NewTaskConsumer(ctx)
+	assert.NoError(t, tc.Register(okTask{}))
+	assert.NoError(t, async.NewTaskProducer().Publish(ctx, c, "task", &async.Param{Name: "asynctest.okTask"}))
+	_, err := tc.Subscribe(c, "task")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(c.Calls()) == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []Call{{Method: MethodAck, Tag: 1}}, c.Calls())
+}
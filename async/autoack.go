@@ -0,0 +1,14 @@
+package async
+
+// WithAutoAck consumes with autoAck, the broker considers a message delivered once it is sent,
+// so the consumer never acks or rejects it and Requeue, NackMultiple and dead lettering have no effect.
+// The delivery is at most once: the messages failing to decode, validate or handle are dropped,
+// as well as the ones in flight or buffered by the client when the consumer stops or the connection drops.
+// It suits the low value queues like a telemetry firehose, where losing a few messages
+// is better than the cost of the acks. The Observer is still notified.
+// A stream queue cannot be consumed with autoAck, see WithStreamOffset.
+func WithAutoAck() func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.AutoAck = true
+	}
+}
@@ -0,0 +1,56 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type autoAckChannel struct {
+	mockChannel
+	mutex   sync.Mutex
+	autoAck bool
+	called  bool
+}
+
+func (a *autoAckChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.autoAck, a.called = autoAck, true
+	return make(chan amqp.Delivery), nil
+}
+
+func (a *autoAckChannel) consumed() (bool, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.autoAck, a.called
+}
+
+func TestWithAutoAck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &autoAckChannel{}
+	_, err := NewTaskConsumer(ctx, WithAutoAck()).Subscribe(c, "telemetry")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, called := c.consumed()
+		return called
+	}, time.Second, 10*time.Millisecond)
+	autoAck, _ := c.consumed()
+	assert.True(t, autoAck)
+
+	for _, body := range []string{`{"name":"async.test"}`, `{"name":"async.testError"}`, `{}`} {
+		ack := &recordAck{}
+		o := &recordObserver{}
+		tc := NewTaskConsumer(context.Background(), WithAutoAck(), WithObserver(o))
+		assert.NoError(t, tc.Register(test{}, testError{}))
+		assert.NoError(t, tc.HandleDelivery(context.Background(), amqp.Delivery{Acknowledger: ack, Body: []byte(body)}))
+		assert.Empty(t, ack.calls)
+		assert.Equal(t, 1, len(o.acked)+len(o.rejected))
+	}
+}
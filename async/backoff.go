@@ -0,0 +1,79 @@
+package async
+
+import (
+	"sync"
+	"time"
+)
+
+// WithBackoff pauses consuming a queue after threshold consecutive handler failures,
+// the pause starts from initial and doubles up to max while the failures go on,
+// the first success resets it. Messages failing to decode or validate are not counted.
+func WithBackoff(threshold int, initial, max time.Duration) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.BackoffThreshold = threshold
+		o.BackoffInitial = initial
+		o.BackoffMax = max
+	}
+}
+
+// breaker counts the consecutive handler failures of a queue, nil is always closed
+type breaker struct {
+	mutex     sync.Mutex
+	threshold int
+	initial   time.Duration
+	max       time.Duration
+	failures  int
+	delay     time.Duration
+}
+
+func newBreaker(threshold int, initial, max time.Duration) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if max < initial {
+		max = initial
+	}
+	return &breaker{threshold: threshold, initial: initial, max: max}
+}
+
+func (b *breaker) failure() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	b.failures++
+	b.mutex.Unlock()
+}
+
+func (b *breaker) success() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	b.failures = 0
+	b.delay = 0
+	b.mutex.Unlock()
+}
+
+// wait returns how long to pause before the next delivery, 0 if not tripped.
+// After a pause one more failure trips it again with a doubled delay.
+func (b *breaker) wait() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.failures < b.threshold {
+		return 0
+	}
+	if b.delay == 0 {
+		b.delay = b.initial
+	} else {
+		b.delay *= 2
+	}
+	if b.delay > b.max {
+		b.delay = b.max
+	}
+	b.failures = b.threshold - 1
+	return b.delay
+}
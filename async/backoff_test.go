@@ -0,0 +1,31 @@
+package async
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreaker(t *testing.T) {
+	assert.Nil(t, newBreaker(0, time.Second, time.Minute))
+	var closed *breaker
+	closed.failure()
+	assert.Equal(t, time.Duration(0), closed.wait())
+
+	b := newBreaker(2, time.Second, 3*time.Second)
+	b.failure()
+	assert.Equal(t, time.Duration(0), b.wait())
+	b.failure()
+	assert.Equal(t, time.Second, b.wait())
+	assert.Equal(t, time.Duration(0), b.wait())
+	b.failure()
+	assert.Equal(t, 2*time.Second, b.wait())
+	b.failure()
+	assert.Equal(t, 3*time.Second, b.wait())
+	b.success()
+	b.failure()
+	assert.Equal(t, time.Duration(0), b.wait())
+	b.failure()
+	assert.Equal(t, time.Second, b.wait())
+}
@@ -65,11 +65,44 @@ func (r *rabbitmqChannel) Consume(queue, consumer string, autoAck, exclusive, no
 	)
 }
 
+func (r *rabbitmqChannel) Confirm(noWait bool) error {
+	return r.channel.Confirm(noWait)
+}
+
+func (r *rabbitmqChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	return r.channel.NotifyPublish(confirm)
+}
+
+func (r *rabbitmqChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool,
+	args amqp.Table) (amqp.Queue, error) {
+	return r.channel.QueueDeclare(name, durable, autoDelete, exclusive, noWait, args)
+}
+
+func (r *rabbitmqChannel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	return r.channel.NotifyReturn(c)
+}
+
+func (r *rabbitmqChannel) QueueInspect(name string) (amqp.Queue, error) {
+	return r.channel.QueueInspect(name)
+}
+
+func (r *rabbitmqChannel) NotifyClose(c chan *amqp.Error) chan *amqp.Error {
+	return r.channel.NotifyClose(c)
+}
+
+func (r *rabbitmqChannel) Cancel(consumer string, noWait bool) error {
+	return r.channel.Cancel(consumer, noWait)
+}
+
 func (r *rabbitmqChannel) Close() error {
 	var errs error
 	if err := r.channel.Close(); err != nil {
 		errs = multierr.Append(errs, err)
 	}
+	// 由NewRabbitmqChannelFactory打开的信道不拥有连接
+	if r.client == nil {
+		return errs
+	}
 	if err := r.client.Close(); err != nil {
 		errs = multierr.Append(errs, err)
 	}
@@ -0,0 +1,112 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/streadway/amqp"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// errChannelClosed is recorded in Stats when the channel is closed without an error
+var errChannelClosed = errors.New("channel closed")
+
+// CloseNotifier is a Channel notifying its close, like *amqp.Channel.
+// The connection closed by a missed heartbeat closes its channels as well.
+type CloseNotifier interface {
+	NotifyClose(c chan *amqp.Error) chan *amqp.Error
+}
+
+// WithCloseHandler sets f to react to the close of a consumed channel, err is nil on a graceful close.
+// It requires a CloseNotifier, the consumption of the queue then stops as soon as the channel is closed,
+// and goes on with the Channel returned by f, e.g. opened by a ChannelFactory over the reconnected connection.
+// The Subscription closes the Channel returned by f once it is done. While f returns an error
+// it is called again with a backoff, and a nil Channel stops the Subscription.
+// Without a CloseHandler the Subscription stops once its channel is closed, see WithReopen.
+func WithCloseHandler(f func(ctx context.Context, err *amqp.Error) (Channel, error)) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.CloseHandler = f
+	}
+}
+
+// WithReopen is WithCloseHandler opening the replacement channel by factory,
+// e.g. NewRabbitmqChannelFactory over a client which reconnects.
+func WithReopen(factory ChannelFactory) func(*ConsumerOption) {
+	return WithCloseHandler(func(context.Context, *amqp.Error) (Channel, error) {
+		return factory()
+	})
+}
+
+// retryInitial and retryMax bounds the backoff of the consume loop between failed attempts
+const (
+	retryInitial = 100 * time.Millisecond
+	retryMax     = 30 * time.Second
+)
+
+// notifyClose registers for the close of channel, nil if it is not a CloseNotifier
+func notifyClose(channel Channel) <-chan *amqp.Error {
+	notifier, ok := channel.(CloseNotifier)
+	if !ok {
+		return nil
+	}
+	// amqp阻塞发送关闭通知，需要缓冲
+	return notifier.NotifyClose(make(chan *amqp.Error, 1))
+}
+
+// receiveClose reports whether the close of the channel is already notified by closed
+func receiveClose(closed <-chan *amqp.Error) (*amqp.Error, bool) {
+	select {
+	case err := <-closed:
+		return err, true
+	default:
+		return nil, false
+	}
+}
+
+func (t *taskConsumer) closed(ctx context.Context, err *amqp.Error) {
+	if err != nil {
+		logger.From(ctx).Error("channel closed: " + err.Error())
+		t.stats.setError(err)
+	} else {
+		logger.From(ctx).Warn(errChannelClosed.Error())
+		t.stats.setError(errChannelClosed)
+	}
+}
+
+// reopen returns the Channel replacing the closed one of s by CloseHandler,
+// false if there is none or the Subscription is stopped meanwhile
+func (t *taskConsumer) reopen(ctx context.Context, s *Subscription, err *amqp.Error, retry *breaker) (Channel, bool) {
+	if t.CloseHandler == nil {
+		return nil, false
+	}
+	for {
+		channel, reopenErr := t.CloseHandler(ctx, err)
+		if reopenErr == nil {
+			return channel, channel != nil
+		}
+		logger.From(ctx).Error(reopenErr.Error())
+		t.stats.setError(reopenErr)
+		retry.failure()
+		if !t.sleep(ctx, s, retry.wait()) {
+			return nil, false
+		}
+	}
+}
+
+// sleep waits for d, false if ctx is done, s is canceled or the Pool is drained before
+func (t *taskConsumer) sleep(ctx context.Context, s *Subscription, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-s.stop:
+		return false
+	case <-t.Pool.Drained():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,210 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// closeChannel 模拟心跳超时关闭信道，投递通道不关闭
+type closeChannel struct {
+	mockChannel
+	mutex      sync.Mutex
+	consumed   int
+	registered int
+	notify     []chan *amqp.Error
+	closed     bool
+}
+
+func (c *closeChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.mutex.Lock()
+	c.consumed++
+	c.mutex.Unlock()
+	return make(chan amqp.Delivery), nil
+}
+
+func (c *closeChannel) NotifyClose(closed chan *amqp.Error) chan *amqp.Error {
+	c.mutex.Lock()
+	c.registered++
+	c.notify = append(c.notify, closed)
+	c.mutex.Unlock()
+	return closed
+}
+
+func (c *closeChannel) close(err *amqp.Error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, closed := range c.notify {
+		closed <- err
+	}
+	c.notify = nil
+}
+
+func (c *closeChannel) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *closeChannel) consumedTimes() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.consumed
+}
+
+func (c *closeChannel) state() (registered int, closed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.registered, c.closed
+}
+
+func TestWithCloseHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	handled := make(chan *amqp.Error, 1)
+	next := &closeChannel{}
+	tc := NewTaskConsumer(ctx, WithCloseHandler(func(ctx context.Context, err *amqp.Error) (Channel, error) {
+		handled <- err
+		return next, nil
+	}))
+	c := &closeChannel{}
+	s, err := tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+
+	closeErr := &amqp.Error{Code: amqp.FrameError, Reason: "missed heartbeats"}
+	c.close(closeErr)
+	select {
+	case err := <-handled:
+		assert.Equal(t, closeErr, err)
+	case <-time.After(time.Second):
+		t.Fatal("close handler not called")
+	}
+	assert.Contains(t, tc.Stats().LastError, "missed heartbeats")
+	// 在返回的信道上重新Consume，不再使用已关闭的信道
+	assert.Eventually(t, func() bool { return next.consumedTimes() == 1 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 1, c.consumedTimes())
+	registered, _ := c.state()
+	assert.Equal(t, 1, registered)
+	registered, _ = next.state()
+	assert.Equal(t, 1, registered)
+	assert.Same(t, next, s.current())
+
+	// 返回的信道由Subscription关闭
+	assert.NoError(t, s.Cancel())
+	<-s.Done()
+	_, closed := next.state()
+	assert.True(t, closed)
+	_, closed = c.state()
+	assert.False(t, closed)
+}
+
+// uncomparableChannel 的动态类型不可比较
+type uncomparableChannel struct {
+	*closeChannel
+	_ func()
+}
+
+func TestCloseHandlerUncomparable(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := &closeChannel{}
+	tc := NewTaskConsumer(ctx, WithCloseHandler(func(ctx context.Context, err *amqp.Error) (Channel, error) {
+		return uncomparableChannel{closeChannel: next}, nil
+	}))
+	c := &closeChannel{}
+	s, err := tc.Subscribe(uncomparableChannel{closeChannel: c}, "a")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+	c.close(nil)
+	assert.Eventually(t, func() bool { return next.consumedTimes() == 1 }, time.Second, 10*time.Millisecond)
+	assert.NoError(t, s.Cancel())
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription is not stopped")
+	}
+	_, closed := next.state()
+	assert.True(t, closed)
+	_, closed = c.state()
+	assert.False(t, closed)
+}
+
+func TestCloseWithoutHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := NewTaskConsumer(ctx)
+	c := &closeChannel{}
+	s, err := tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+	c.close(nil)
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription is not stopped")
+	}
+	assert.Equal(t, 1, c.consumedTimes())
+	assert.Equal(t, errChannelClosed.Error(), tc.Stats().LastError)
+}
+
+// failChannel Consume总是失败
+type failChannel struct {
+	closeChannel
+}
+
+func (f *failChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	f.closeChannel.mutex.Lock()
+	f.consumed++
+	f.closeChannel.mutex.Unlock()
+	return nil, amqp.ErrClosed
+}
+
+func TestConsumeBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := NewTaskConsumer(ctx)
+	c := &failChannel{}
+	_, err := tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	// 退避100ms、200ms...，不在失败的信道上空转
+	time.Sleep(250 * time.Millisecond)
+	assert.LessOrEqual(t, c.consumedTimes(), 3)
+	assert.GreaterOrEqual(t, c.consumedTimes(), 2)
+	registered, _ := c.state()
+	assert.Equal(t, 1, registered)
+	assert.Equal(t, amqp.ErrClosed.Error(), tc.Stats().LastError)
+}
+
+func TestWithReopen(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mutex sync.Mutex
+	opened := 0
+	next := &closeChannel{}
+	tc := NewTaskConsumer(ctx, WithReopen(func() (Channel, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		// 第一次重连失败，退避后重试
+		if opened++; opened == 1 {
+			return nil, amqp.ErrClosed
+		}
+		return next, nil
+	}))
+	c := &closeChannel{}
+	_, err := tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return c.consumedTimes() == 1 }, time.Second, 10*time.Millisecond)
+	c.close(&amqp.Error{Code: amqp.ConnectionForced, Reason: "shutdown"})
+	assert.Eventually(t, func() bool { return next.consumedTimes() == 1 }, time.Second, 10*time.Millisecond)
+	mutex.Lock()
+	assert.Equal(t, 2, opened)
+	mutex.Unlock()
+}
@@ -0,0 +1,47 @@
+package async
+
+import (
+	"mime"
+	"strings"
+
+	"github.com/crochee/lirity/codec"
+)
+
+// Codec decodes the payload of a content type into Param, codec.JSON implements it.
+type Codec interface {
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// WithUseNumber decodes the numbers of the payloads in interface{}, like Param.Metadata, as json.Number,
+// so that the int64 ids keep their precision. It replaces JSONHandler by codec.Number,
+// set JSONHandler to jsoniter.New of codec/jsoniter for a custom jsoniter API.
+func WithUseNumber() func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.JSONHandler = codec.Number
+	}
+}
+
+// RegisterCodec registers the codec decoding the deliveries of contentType,
+// the payload of an empty or unregistered content type is decoded with JSONHandler.
+// It is not goroutine safe, call it before Subscribe.
+func (t *taskConsumer) RegisterCodec(contentType string, codec Codec) {
+	if t.Codecs == nil {
+		t.Codecs = make(map[string]Codec)
+	}
+	t.Codecs[mediaType(contentType)] = codec
+}
+
+func (t *taskConsumer) codec(contentType string) Codec {
+	if codec, ok := t.Codecs[mediaType(contentType)]; ok {
+		return codec
+	}
+	return t.JSONHandler
+}
+
+// mediaType strips the parameters like charset and lowers the content type
+func mediaType(contentType string) string {
+	if v, _, err := mime.ParseMediaType(contentType); err == nil {
+		return v
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
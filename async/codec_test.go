@@ -0,0 +1,53 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// nameCodec 把整个payload作为Param.Name
+type nameCodec struct{}
+
+func (nameCodec) Unmarshal(data []byte, v interface{}) error {
+	v.(*Param).Name = string(data)
+	return nil
+}
+
+func TestRegisterCodec(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "default json", body: `{"name":"async.ctxTest"}`},
+		{name: "unknown json", contentType: "application/octet-stream", body: `{"name":"async.ctxTest"}`},
+		{name: "registered", contentType: "application/x-name", body: "async.ctxTest"},
+		{name: "with parameter", contentType: "Application/X-Name; charset=utf-8", body: "async.ctxTest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &ctxTest{ctx: make(chan context.Context, 1)}
+			tc := NewTaskConsumer(context.Background())
+			assert.NoError(t, tc.Register(executor))
+			tc.RegisterCodec("application/x-name", nameCodec{})
+			d := amqp.Delivery{Acknowledger: mockAck{}, ContentType: tt.contentType, Body: []byte(tt.body)}
+			assert.NoError(t, tc.handle(context.Background(), d, newTagTracker(false), nil))
+			assert.Len(t, executor.ctx, 1)
+		})
+	}
+}
+
+func TestWithUseNumber(t *testing.T) {
+	body := []byte(`{"name":"async.ctxTest","metadata":{"id":9007199254740993}}`)
+	param := &Param{}
+	assert.NoError(t, NewTaskConsumer(context.Background(), WithUseNumber()).codec("").Unmarshal(body, param))
+	assert.Equal(t, json.Number("9007199254740993"), param.Metadata["id"])
+
+	param = &Param{}
+	assert.NoError(t, NewTaskConsumer(context.Background()).codec("").Unmarshal(body, param))
+	assert.IsType(t, float64(0), param.Metadata["id"])
+}
@@ -0,0 +1,57 @@
+package async
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EncodingGzip is the ContentEncoding of the payloads compressed by WithGzip
+const EncodingGzip = "gzip"
+
+// ErrUnsupportedEncoding is the ContentEncoding of a delivery which the consumer can't decompress,
+// such a delivery is rejected as RejectContentEncoding rather than decoded as is.
+var ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+
+// WithGzip compresses the payloads by gzip and sets their ContentEncoding, off by default.
+// The consumers decompress them transparently, it is worth it for the large payloads.
+func WithGzip() func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.Gzip = true
+	}
+}
+
+func compress(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress returns the body of the content encoding, an empty or identity one is kept as is
+func decompress(contentEncoding string, body []byte) ([]byte, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("cann't decompress gzip body,%w", err)
+		}
+		defer r.Close()
+		if body, err = io.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("cann't decompress gzip body,%w", err)
+		}
+		return body, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedEncoding, contentEncoding)
+	}
+}
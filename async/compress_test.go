@@ -0,0 +1,63 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithGzip(t *testing.T) {
+	ctx := context.Background()
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	data := bytes.Repeat([]byte("payload"), 1024)
+	assert.NoError(t, NewTaskProducer(WithGzip()).Publish(ctx, c, "task", &Param{Name: "async.nopTest", Data: data}))
+	d := <-c.deliveries
+	assert.Equal(t, EncodingGzip, d.ContentEncoding)
+	assert.Less(t, len(d.Body), len(data))
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		want     []ackCall
+		rejected []RejectReason
+	}{
+		{name: "gzip", encoding: d.ContentEncoding, body: d.Body, want: []ackCall{{method: "ack", tag: 1}}},
+		{
+			name:     "unknown encoding",
+			encoding: "br",
+			body:     d.Body,
+			want:     []ackCall{{method: "reject", tag: 1}},
+			rejected: []RejectReason{RejectContentEncoding},
+		},
+		{
+			name:     "corrupt",
+			encoding: EncodingGzip,
+			body:     []byte(`{"name":"async.nopTest"}`),
+			want:     []ackCall{{method: "reject", tag: 1}},
+			rejected: []RejectReason{RejectContentEncoding},
+		},
+		{name: "identity", encoding: "identity", body: []byte(`{"name":"async.nopTest"}`), want: []ackCall{{method: "ack", tag: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &recordObserver{}
+			tc := NewTaskConsumer(ctx, WithObserver(o))
+			assert.NoError(t, tc.Register(nopTest{}))
+			ack := &recordAck{}
+			assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+				Acknowledger:    ack,
+				DeliveryTag:     1,
+				Headers:         d.Headers,
+				ContentEncoding: tt.encoding,
+				Body:            tt.body,
+			}))
+			assert.Equal(t, tt.want, ack.calls)
+			assert.Equal(t, tt.rejected, o.rejected)
+		})
+	}
+	assert.Equal(t, "content_encoding", RejectContentEncoding.String())
+}
@@ -0,0 +1,241 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrNack is returned by Publish when the broker nacks the message in confirm mode.
+	ErrNack = errors.New("publish nacked by broker")
+	// ErrConfirmTimeout is returned by Publish when no confirmation arrives in time.
+	ErrConfirmTimeout = errors.New("publish confirm timeout")
+	// ErrConfirmClosed is returned by Publish when the channel is closed before the confirmation.
+	ErrConfirmClosed = errors.New("channel closed before publish confirm")
+	// ErrConfirmNotSupported is returned by Publish in confirm mode if the Channel is not a ConfirmChannel.
+	ErrConfirmNotSupported = errors.New("channel does not support publisher confirms")
+	// ErrChannelNotComparable is returned by Publish in confirm mode if the Channel is not comparable, e.g. a struct
+	// value holding a slice, since its confirmations are tracked per channel. Pass a pointer instead.
+	ErrChannelNotComparable = errors.New("channel is not comparable")
+	// ErrUnroutable is returned by Publish with WithMandatory when the broker returns the message
+	// since no queue is bound for its routing key.
+	ErrUnroutable = errors.New("publish returned as unroutable")
+	// ErrReturnNotSupported is returned by Publish with WithMandatory if the Channel is not a ReturnChannel.
+	ErrReturnNotSupported = errors.New("channel does not support returned messages")
+	// ErrMandatoryWithoutConfirm is returned by Publish with WithMandatory if the producer is not in confirm mode,
+	// without the confirmation there is no telling when a message won't be returned.
+	ErrMandatoryWithoutConfirm = errors.New("mandatory publishing requires publisher confirms")
+)
+
+// DefaultConfirmTimeout is the confirm timeout used by WithConfirms.
+const DefaultConfirmTimeout = 5 * time.Second
+
+// ConfirmChannel is a Channel supporting publisher confirms, like *amqp.Channel.
+type ConfirmChannel interface {
+	Channel
+	Confirm(noWait bool) error
+	NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation
+}
+
+// ReturnChannel is a Channel notifying the returned messages, like *amqp.Channel.
+type ReturnChannel interface {
+	NotifyReturn(c chan amqp.Return) chan amqp.Return
+}
+
+// WithMandatory publishes the message with the mandatory flag, so that Publish returns ErrUnroutable
+// if no queue is bound for its routing key instead of losing it silently.
+// It requires the producer in confirm mode and a ReturnChannel, the MessageId is set to the uuid if empty.
+func WithMandatory() func(*PublishOption) {
+	return func(o *PublishOption) {
+		o.Mandatory = true
+	}
+}
+
+// WithConfirms puts the channels in confirm mode, Publish waits for the broker ack
+// for at most DefaultConfirmTimeout, and returns ErrNack on nack.
+func WithConfirms() func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.Confirm = true
+		if o.ConfirmTimeout <= 0 {
+			o.ConfirmTimeout = DefaultConfirmTimeout
+		}
+	}
+}
+
+// WithConfirmTimeout sets how long Publish waits for a confirmation, it implies WithConfirms.
+func WithConfirmTimeout(timeout time.Duration) func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.Confirm = true
+		o.ConfirmTimeout = timeout
+	}
+}
+
+// confirmer matches the confirmations of a channel to the waiting publishes by delivery tag,
+// which rabbitmq assigns from 1 in publish order once the channel is in confirm mode.
+// So all the publishes on the channel must go through the same confirmer.
+// The mandatory messages returned as unroutable are matched by MessageId, rabbitmq sends
+// the return before the confirmation of the message.
+type confirmer struct {
+	mutex    sync.Mutex
+	channel  ConfirmChannel
+	returns  bool
+	seq      uint64
+	pending  map[uint64]chan confirmation
+	ids      map[string]uint64
+	returned map[uint64]amqp.Return
+	closed   bool
+}
+
+// confirmation is the confirmation of a publish, with the return if the message was unroutable
+type confirmation struct {
+	amqp.Confirmation
+	returned *amqp.Return
+}
+
+func newConfirmer(channel ConfirmChannel) (*confirmer, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("cann't put channel in confirm mode,%w", err)
+	}
+	c := &confirmer{
+		channel:  channel,
+		pending:  make(map[uint64]chan confirmation),
+		ids:      make(map[string]uint64),
+		returned: make(map[uint64]amqp.Return),
+	}
+	var returns chan amqp.Return
+	if returnChannel, ok := channel.(ReturnChannel); ok {
+		c.returns = true
+		returns = returnChannel.NotifyReturn(make(chan amqp.Return, 64))
+	}
+	go c.dispatch(channel.NotifyPublish(make(chan amqp.Confirmation, 64)), returns)
+	return c, nil
+}
+
+func (c *confirmer) dispatch(confirms <-chan amqp.Confirmation, returns <-chan amqp.Return) {
+	// 必须及时读取，否则amqp会阻塞整个信道
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				returns = nil
+				continue
+			}
+			c.returnMessage(ret)
+		case confirm, ok := <-confirms:
+			if !ok {
+				c.close()
+				return
+			}
+			// 退回先于确认到达，但可能还未被读取
+			c.drainReturns(returns)
+			c.mutex.Lock()
+			if wait, ok := c.pending[confirm.DeliveryTag]; ok {
+				delete(c.pending, confirm.DeliveryTag)
+				result := confirmation{Confirmation: confirm}
+				if ret, ok := c.returned[confirm.DeliveryTag]; ok {
+					delete(c.returned, confirm.DeliveryTag)
+					result.returned = &ret
+				}
+				wait <- result
+			}
+			c.mutex.Unlock()
+		}
+	}
+}
+
+func (c *confirmer) drainReturns(returns <-chan amqp.Return) {
+	for {
+		select {
+		case ret, ok := <-returns:
+			if !ok {
+				return
+			}
+			c.returnMessage(ret)
+		default:
+			return
+		}
+	}
+}
+
+// returnMessage records the return for the confirmation of the message
+func (c *confirmer) returnMessage(ret amqp.Return) {
+	c.mutex.Lock()
+	if tag, ok := c.ids[ret.MessageId]; ok {
+		delete(c.ids, ret.MessageId)
+		c.returned[tag] = ret
+	}
+	c.mutex.Unlock()
+}
+
+func (c *confirmer) close() {
+	c.mutex.Lock()
+	c.closed = true
+	for tag, wait := range c.pending {
+		delete(c.pending, tag)
+		close(wait)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *confirmer) publish(ctx context.Context, timeout time.Duration,
+	exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return ErrConfirmClosed
+	}
+	if mandatory && !c.returns {
+		c.mutex.Unlock()
+		return ErrReturnNotSupported
+	}
+	if err := c.channel.Publish(exchange, key, mandatory, immediate, msg); err != nil {
+		c.mutex.Unlock()
+		return err
+	}
+	c.seq++
+	tag := c.seq
+	wait := make(chan confirmation, 1)
+	c.pending[tag] = wait
+	if mandatory {
+		c.ids[msg.MessageId] = tag
+	}
+	c.mutex.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case confirm, ok := <-wait:
+		if !ok {
+			return ErrConfirmClosed
+		}
+		if confirm.returned != nil {
+			return fmt.Errorf("%w,%d %s", ErrUnroutable, confirm.returned.ReplyCode, confirm.returned.ReplyText)
+		}
+		if !confirm.Ack {
+			return ErrNack
+		}
+		return nil
+	case <-timer.C:
+		c.forget(tag, msg.MessageId)
+		return ErrConfirmTimeout
+	case <-ctx.Done():
+		c.forget(tag, msg.MessageId)
+		return ctx.Err()
+	}
+}
+
+// forget drops the waiter so that a late confirmation is discarded
+func (c *confirmer) forget(tag uint64, messageID string) {
+	c.mutex.Lock()
+	delete(c.pending, tag)
+	delete(c.returned, tag)
+	if c.ids[messageID] == tag {
+		delete(c.ids, messageID)
+	}
+	c.mutex.Unlock()
+}
@@ -0,0 +1,142 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type confirmChannel struct {
+	mockChannel
+	mutex  sync.Mutex
+	tag    uint64
+	notify chan amqp.Confirmation
+	// reply 返回tag对应的确认，false表示不确认
+	reply func(tag uint64) (amqp.Confirmation, bool)
+}
+
+func (c *confirmChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.mutex.Lock()
+	c.tag++
+	tag := c.tag
+	c.mutex.Unlock()
+	if confirm, ok := c.reply(tag); ok {
+		go func() { c.notify <- confirm }()
+	}
+	return nil
+}
+
+func (c *confirmChannel) Confirm(noWait bool) error {
+	return nil
+}
+
+func (c *confirmChannel) NotifyPublish(confirm chan amqp.Confirmation) chan amqp.Confirmation {
+	c.notify = confirm
+	return confirm
+}
+
+func TestPublishConfirm(t *testing.T) {
+	param := &Param{Name: "async.test"}
+	tests := []struct {
+		name  string
+		reply func(tag uint64) (amqp.Confirmation, bool)
+		want  error
+	}{
+		{
+			name: "ack",
+			reply: func(tag uint64) (amqp.Confirmation, bool) {
+				return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+			},
+		},
+		{
+			name: "nack",
+			reply: func(tag uint64) (amqp.Confirmation, bool) {
+				return amqp.Confirmation{DeliveryTag: tag}, true
+			},
+			want: ErrNack,
+		},
+		{
+			name: "timeout",
+			reply: func(tag uint64) (amqp.Confirmation, bool) {
+				return amqp.Confirmation{}, false
+			},
+			want: ErrConfirmTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := NewTaskProducer(WithConfirmTimeout(50 * time.Millisecond))
+			err := tp.Publish(context.Background(), &confirmChannel{reply: tt.reply}, "test", param)
+			assert.True(t, errors.Is(err, tt.want), err)
+		})
+	}
+
+	t.Run("late confirm is discarded", func(t *testing.T) {
+		tp := NewTaskProducer(WithConfirmTimeout(50 * time.Millisecond))
+		late := make(chan struct{})
+		c := &confirmChannel{}
+		c.reply = func(tag uint64) (amqp.Confirmation, bool) {
+			if tag == 1 {
+				go func() {
+					<-late
+					c.notify <- amqp.Confirmation{DeliveryTag: 1}
+				}()
+				return amqp.Confirmation{}, false
+			}
+			return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+		}
+		assert.Equal(t, ErrConfirmTimeout, tp.Publish(context.Background(), c, "test", param))
+		close(late)
+		assert.NoError(t, tp.Publish(context.Background(), c, "test", param))
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		tp := NewTaskProducer(WithConfirms())
+		ctx, cancel := context.WithCancel(context.Background())
+		c := &confirmChannel{reply: func(tag uint64) (amqp.Confirmation, bool) {
+			cancel()
+			return amqp.Confirmation{}, false
+		}}
+		assert.Equal(t, context.Canceled, tp.Publish(ctx, c, "test", param))
+	})
+
+	t.Run("channel closed", func(t *testing.T) {
+		tp := NewTaskProducer(WithConfirms())
+		c := &confirmChannel{}
+		c.reply = func(tag uint64) (amqp.Confirmation, bool) {
+			go close(c.notify)
+			return amqp.Confirmation{}, false
+		}
+		assert.Equal(t, ErrConfirmClosed, tp.Publish(context.Background(), c, "test", param))
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		tp := NewTaskProducer(WithConfirms())
+		assert.Equal(t, ErrConfirmNotSupported, tp.Publish(context.Background(), &mockChannel{}, "test", param))
+	})
+}
+
+// valueChannel 是不可比较的Channel值
+type valueChannel struct {
+	*confirmChannel
+	tags []uint64
+}
+
+func TestPublishConfirmNotComparable(t *testing.T) {
+	tp := NewTaskProducer(WithConfirms())
+	reply := func(tag uint64) (amqp.Confirmation, bool) {
+		return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+	}
+	param := &Param{Name: "async.test"}
+	assert.NotPanics(t, func() {
+		err := tp.Publish(context.Background(), valueChannel{confirmChannel: &confirmChannel{reply: reply}}, "test", param)
+		assert.ErrorIs(t, err, ErrChannelNotComparable)
+	})
+	assert.NoError(t, tp.Publish(context.Background(), &valueChannel{confirmChannel: &confirmChannel{reply: reply}},
+		"test", param))
+}
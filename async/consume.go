@@ -2,30 +2,41 @@ package async
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"sync"
+	"time"
 
-	"github.com/json-iterator/go"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
 
+	"github.com/crochee/lirity/codec"
+	"github.com/crochee/lirity/e"
 	"github.com/crochee/lirity/logger"
 	"github.com/crochee/lirity/mq"
 	"github.com/crochee/lirity/routine"
 	"github.com/crochee/lirity/validator"
 )
 
+// NewTaskConsumer creates a consumer logging by the logger in ctx, see logger.With,
+// inject logger.Nop() to measure the handlers without the logging cost.
 func NewTaskConsumer(ctx context.Context, opts ...func(*ConsumerOption)) *taskConsumer {
 	t := &taskConsumer{
+		ctx:   ctx,
+		stats: newConsumerStats(),
 		ConsumerOption: ConsumerOption{
-			Pool: routine.NewPool(ctx, routine.Recover(func(ctx context.Context, i interface{}) {
-				logger.From(ctx).Sugar().Errorf("%v", i)
-			})),
 			Manager:     NewManager(),
 			Marshal:     mq.DefaultMarshal{},
-			JSONHandler: jsoniter.ConfigCompatibleWithStandardLibrary,
+			JSONHandler: codec.Default,
 			ParamPool:   NewParamPool(),
 			Validator:   validator.NewValidator(),
+			ConsumerTag: defaultConsumerTag,
 		},
 	}
+	t.Pool = routine.NewPool(ctx, routine.RecoverWithStack(t.recover))
 	for _, opt := range opts {
 		opt(&t.ConsumerOption)
 	}
@@ -36,12 +47,82 @@ type ConsumerOption struct {
 	Pool        *routine.Pool   // goroutine safe run pool
 	Manager     ManagerExecutor // manager executor how to run
 	Marshal     mq.MarshalAPI   // mq  assemble request or response
-	JSONHandler jsoniter.API
-	ParamPool   ParamPool // get Param
-	Validator   validator.Validator
+	JSONHandler codec.JSON      // codec.Default of encoding/json, or jsoniter.Default of codec/jsoniter
+	ParamPool   ParamPool       // get Param
+	// Validator 校验解码后的参数，validator.WithTaggedOnly时只校验带binding标签的字段
+	Validator validator.Validator
+	// Requeue 执行失败的消息是否重新入队，解析或校验失败的消息总是直接丢弃
+	Requeue bool
+	// NackMultiple 执行失败时使用Nack(multiple=true)批量否定确认，
+	// 仅当更小的tag都已处理完后才会发送
+	NackMultiple bool
+	// ConsumerTag 根据队列名生成消费者标签
+	ConsumerTag func(queue string) string
+	// Codecs 按ContentType选择payload的解码器，见RegisterCodec
+	Codecs map[string]Codec
+	// 连续执行失败BackoffThreshold次后暂停消费，见WithBackoff
+	BackoffThreshold int
+	BackoffInitial   time.Duration
+	BackoffMax       time.Duration
+	// DeclareQueue 消费前以QueueArgs声明队列，见WithDeclareQueue和WithDeadLetter
+	DeclareQueue bool
+	QueueArgs    amqp.Table
+	// ConsumeArgs Consume的参数，见WithStreamOffset
+	ConsumeArgs amqp.Table
+	// Observer 消息确认或拒绝时通知，见WithObserver
+	Observer Observer
+	// Channels SubscribeAll打开的信道数，见WithChannels
+	Channels int
+	// RecoverHandler 在记录日志后处理panic，SkipRecoverLog时不记录日志，见WithRecoverHandler
+	RecoverHandler func(ctx context.Context, r interface{})
+	SkipRecoverLog bool
+	// RecoverLevel 根据panic的值决定日志级别，nil时为Error，见WithRecoverLevel
+	RecoverLevel func(r interface{}) zapcore.Level
+	// AutoAck 由broker在投递时确认，不再确认或拒绝，见WithAutoAck
+	AutoAck bool
+	// RateLimiter 限制所有队列的处理速率，QueueRateLimiters按队列限制，见WithRateLimit
+	RateLimiter       *rate.Limiter
+	QueueRateLimiters map[string]*rate.Limiter
+	// ContextExtractor 处理前从消息中提取值放入context，见WithContextExtractor
+	ContextExtractor func(ctx context.Context, d amqp.Delivery) context.Context
+	// Schemas 按任务名校验Param.Data的JSON Schema，见RegisterSchema
+	Schemas map[string]*jsonschema.Schema
+	// PayloadRedactor 在Debug级别记录payload前脱敏，见WithPayloadRedactor
+	PayloadRedactor func([]byte) []byte
+	// CloseHandler 信道关闭时调用并返回替代的信道，见WithCloseHandler
+	CloseHandler func(ctx context.Context, err *amqp.Error) (Channel, error)
+	// TracePropagation 从headers提取trace并创建span，见WithTraceExtraction
+	TracePropagation bool
+	// StaleTolerance 消息过期后仍处理的时长，用于容忍时钟偏差，见WithStaleTolerance
+	StaleTolerance time.Duration
+}
+
+// WithContextExtractor sets f to derive the context of each delivery before it is logged and handled,
+// e.g. to promote the tenant and trace id in the headers into the context and its logger by logger.WithField,
+// so that they reach every log line and the handlers through Manager.Run.
+func WithContextExtractor(f func(ctx context.Context, d amqp.Delivery) context.Context) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.ContextExtractor = f
+	}
+}
+
+// WithConsumerTag sets how the consumer tag is named from the queue name,
+// e.g. to include the hostname so that replicas are distinguishable.
+func WithConsumerTag(f func(queue string) string) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.ConsumerTag = f
+	}
+}
+
+func defaultConsumerTag(queue string) string {
+	return "consumer." + queue
 }
 
 type taskConsumer struct {
+	ctx           context.Context
+	stats         *consumerStats
+	mutex         sync.Mutex
+	subscriptions map[string]*Subscription
 	ConsumerOption
 }
 
@@ -49,100 +130,356 @@ func (t *taskConsumer) Register(executors ...Executor) error {
 	return t.Manager.Register(executors...)
 }
 
-func (t *taskConsumer) Subscribe(channel Channel, queueName string) error {
-	t.Pool.Go(func(ctx context.Context) {
+// Subscribe starts consuming queueName on channel until the context of the consumer is done
+// or the returned Subscription is canceled, it returns without waiting, see Wait.
+func (t *taskConsumer) Subscribe(channel Channel, queueName string) (*Subscription, error) {
+	if _, ok := channel.(QueueDeclarer); t.DeclareQueue && !ok {
+		return nil, ErrDeclareNotSupported
+	}
+	if _, ok := t.ConsumeArgs[streamOffsetArg]; ok && t.AutoAck {
+		return nil, ErrStreamAutoAck
+	}
+	consumerTag := t.ConsumerTag(queueName)
+	s := newSubscription(channel, queueName, consumerTag)
+	if err := t.addSubscription(s); err != nil {
+		return nil, err
+	}
+	t.stats.setQueue(queueName, consumerTag, false)
+	b := newBreaker(t.BackoffThreshold, t.BackoffInitial, t.BackoffMax)
+	// 消费循环常驻，不占用Size限制的名额，否则处理消息的goroutine无法启动
+	started := t.Pool.TryGoUnbounded(func(ctx context.Context) {
+		// replaced 表示信道已由CloseHandler替换，不比较Channel，其动态类型可能不可比较
+		replaced := false
+		defer func() {
+			t.removeSubscription(s)
+			t.stats.removeQueue(queueName)
+			if replaced {
+				// 关闭信道后由CloseHandler提供的信道归Subscription所有
+				_ = s.current().Close()
+			}
+			close(s.done)
+		}()
+		ctx = logger.WithContextFields(withQueue(ctx, queueName),
+			zap.String("queue", queueName),
+			zap.String("consumer_tag", consumerTag),
+		)
+		current := channel
+		// 每个信道只注册一次关闭通知
+		closed := notifyClose(current)
+		// 声明或Consume失败后退避重试
+		retry := newBreaker(1, retryInitial, retryMax)
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-s.stop:
+				return
+			case <-t.Pool.Drained():
+				// 停止消费，已收到未处理的消息在信道关闭后重新投递
+				_ = s.Cancel()
+				return
 			default:
 			}
-			deliveries, err := channel.Consume(
-				queueName,
-				// 用来区分多个消费者
-				"consumer."+queueName,
-				// 是否自动应答(自动应答确认消息，这里设置为否，在下面手动应答确认)
-				false,
-				// 是否具有排他性
-				false,
-				// 如果设置为true，表示不能将同一个connection中发送的消息
-				// 传递给同一个connection的消费者
-				false,
-				// 是否为阻塞
-				false,
-				nil,
-			)
-			if err != nil {
+			if delay := retry.wait(); delay > 0 && !t.sleep(ctx, s, delay) {
+				return
+			}
+			var closeErr *amqp.Error
+			isClosed := false
+			if err := t.declare(current, queueName); err != nil {
 				logger.From(ctx).Error(err.Error())
-				fmt.Println(err)
+				t.stats.setError(err)
+				retry.failure()
+				closeErr, isClosed = receiveClose(closed)
+			} else {
+				deliveries, err := s.consume(func() (<-chan amqp.Delivery, error) {
+					return current.Consume(
+						queueName,
+						// 用来区分多个消费者
+						consumerTag,
+						// 是否自动应答(自动应答确认消息，默认为否，在下面手动应答确认)
+						t.AutoAck,
+						// 是否具有排他性
+						false,
+						// 如果设置为true，表示不能将同一个connection中发送的消息
+						// 传递给同一个connection的消费者
+						false,
+						// 是否为阻塞
+						false,
+						t.ConsumeArgs,
+					)
+				})
+				if errors.Is(err, errSubscriptionCanceled) {
+					return
+				}
+				if err != nil {
+					logger.From(ctx).Error(err.Error())
+					t.stats.setError(err)
+					retry.failure()
+					// 信道已关闭时Consume失败，不再在其上重试
+					closeErr, isClosed = receiveClose(closed)
+				} else {
+					retry.success()
+					t.stats.setConsuming(queueName, true)
+					closeErr, isClosed = t.handleMessage(ctx, deliveries, b, t.limiter(queueName), s.stopped(), closed)
+					t.stats.setConsuming(queueName, false)
+				}
+			}
+			if !isClosed {
 				continue
 			}
-			t.handleMessage(ctx, deliveries)
+			t.closed(ctx, closeErr)
+			next, ok := t.reopen(ctx, s, closeErr, retry)
+			if !ok {
+				return
+			}
+			if replaced {
+				_ = current.Close()
+			}
+			current, replaced = next, true
+			s.setChannel(current)
+			closed = notifyClose(current)
 		}
 	})
-	t.Pool.Wait()
-	return nil
+	if !started {
+		// 消费者已停止，如重连与关闭竞争
+		t.removeSubscription(s)
+		t.stats.removeQueue(queueName)
+		close(s.done)
+		return nil, ErrConsumerStopped
+	}
+	return s, nil
+}
+
+// Wait waits for the subscriptions and their deliveries until the context of the consumer is done,
+// it returns the errors collected by Pool.
+func (t *taskConsumer) Wait() error {
+	return t.Pool.Wait()
 }
 
-func (t *taskConsumer) handleMessage(ctx context.Context, deliveries <-chan amqp.Delivery) {
+// handleMessage handles deliveries until they are closed, or stop is closed, or the Pool is drained,
+// or the channel is closed as notified by closed, which is reported by isClosed
+func (t *taskConsumer) handleMessage(ctx context.Context, deliveries <-chan amqp.Delivery, b *breaker,
+	limiter *rate.Limiter, stop <-chan struct{}, closed <-chan *amqp.Error) (closeErr *amqp.Error, isClosed bool) {
+	tracker := newTagTracker(t.Requeue)
+	// 返回前等待已开始处理的消息，使Subscription.Done之后不再使用信道
+	var handlers sync.WaitGroup
+	defer handlers.Wait()
 	for {
+		if delay := b.wait(); delay > 0 {
+			logger.From(ctx).Sugar().Warnf("too many failures, pause consuming for %s", delay)
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.Pool.Drained():
+				return
+			case <-time.After(delay):
+			}
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case v := <-deliveries:
-			t.Pool.Go(func(ctx context.Context) {
-				if err := t.handle(ctx, v); err != nil {
+		case <-stop:
+			return
+		case <-t.Pool.Drained():
+			return
+		case err := <-closed:
+			// 不等待投递通道关闭或下一次Consume失败
+			return err, true
+		case v, ok := <-deliveries:
+			if !ok {
+				// amqp先通知关闭再关闭投递通道，否则由Subscribe重新Consume
+				return receiveClose(closed)
+			}
+			if !t.AutoAck {
+				tracker.add(v)
+			}
+			if err := wait(ctx, limiter); err != nil {
+				// 等待令牌时停止消费
+				if err = t.requeue(ctx, v, tracker); err != nil {
+					logger.From(ctx).Error(err.Error())
+				}
+				return
+			}
+			t.stats.addInFlight(1)
+			handlers.Add(1)
+			// 继承Subscribe中加入logger的字段
+			started := t.Pool.GoWith(ctx, func(ctx context.Context) {
+				defer handlers.Done()
+				defer t.stats.addInFlight(-1)
+				if err := t.handle(ctx, v, tracker, b); err != nil {
 					logger.From(ctx).Error(err.Error())
+					t.stats.setError(err)
 				}
 			})
+			if !started {
+				// Pool已停止或已Drain，消息未被处理，不再消费
+				handlers.Done()
+				t.stats.addInFlight(-1)
+				if err := t.requeue(ctx, v, tracker); err != nil {
+					logger.From(ctx).Error(err.Error())
+				}
+				return
+			}
 		}
 	}
 }
 
+// HandleDelivery runs a single delivery through the consumer pipeline and acks it,
+// it is for the tests and the custom consume loops, see asynctest.Deliver.
+func (t *taskConsumer) HandleDelivery(ctx context.Context, d amqp.Delivery) error {
+	tracker := newTagTracker(t.Requeue)
+	tracker.add(d)
+	return t.handle(ctx, d, tracker, nil)
+}
+
 // nolint:gocritic
-func (t *taskConsumer) handle(ctx context.Context, d amqp.Delivery) error {
-	msgStruct, err := t.Marshal.Unmarshal(&d)
+func (t *taskConsumer) handle(ctx context.Context, d amqp.Delivery, tracker *tagTracker, b *breaker) (err error) {
+	if t.TracePropagation {
+		var span trace.Span
+		ctx, span = startConsumeSpan(ctx, d)
+		defer func() {
+			endSpan(span, err)
+		}()
+	}
+	if t.ContextExtractor != nil {
+		ctx = t.ContextExtractor(ctx, d)
+	}
+	// 队列名和消费者标签已由Subscribe加入logger
+	ctx = logger.WithContextFields(ctx,
+		zap.Uint64("delivery_tag", d.DeliveryTag),
+		zap.Bool("redelivered", d.Redelivered),
+	)
+	if ctx.Err() != nil {
+		// 已停止消费，未开始执行的消息重新入队交给其他消费者
+		return t.requeue(ctx, d, tracker)
+	}
+	if deadline, ok := t.stale(&d); ok {
+		return t.dropStale(ctx, d, tracker, deadline)
+	}
+	body, err := decompress(d.ContentEncoding, d.Body)
 	if err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		return t.reject(ctx, d, tracker, RejectContentEncoding)
+	}
+	d.Body, d.ContentEncoding = body, ""
+	envelope := d
+	envelope.Headers = metadataHeaders(d.Headers)
+	msgStruct, err := t.Marshal.Unmarshal(&envelope)
+	if err != nil {
+		logger.From(ctx).Error(err.Error())
+		return t.reject(ctx, d, tracker, RejectUnmarshalEnvelope)
 	}
-	logger.From(ctx).Sugar().Infof("consume uuid %s body:%s", msgStruct.UUID, msgStruct.Payload)
+	ctx = logger.WithContextFields(ctx, zap.String("uuid", msgStruct.UUID))
+	t.logPayload(ctx, msgStruct.Payload)
 	param := t.ParamPool.Get()
-	if err = t.JSONHandler.Unmarshal(msgStruct.Payload, param); err != nil {
+	if err = t.codec(d.ContentType).Unmarshal(msgStruct.Payload, param); err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		return t.reject(ctx, d, tracker, RejectUnmarshalPayload)
 	}
 	if err = t.Validator.ValidateStruct(param); err != nil {
+		logger.From(ctx).Error(e.FromValidationError(err).Error())
+		return t.reject(ctx, d, tracker, RejectValidationFailed)
+	}
+	if err = t.validateSchema(param); err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil { // nolint:gocritic
-			return err
-		}
-		return nil
+		return t.reject(ctx, d, tracker, RejectValidationFailed)
 	}
-	err = t.Manager.Run(ctx, param)
+	// 传递副本，避免handler修改影响后续确认
+	raw := d
+	err = t.run(withDelivery(withPriority(withUUID(ctx, msgStruct.UUID), d.Priority), &raw), param)
 	t.ParamPool.Put(param)
 	if err != nil {
 		logger.From(ctx).Error(err.Error())
-		// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
-		// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
-		if err = d.Reject(false); err != nil {
+		if t.TracePropagation {
+			// 执行失败的消息被否定确认，handle不返回err
+			setSpanError(trace.SpanFromContext(ctx), err)
+		}
+		if errors.Is(err, ErrUnknownVersion) {
+			// 未知版本重试也不会成功
+			return t.reject(ctx, d, tracker, RejectUnknownVersion)
+		}
+		b.failure()
+		return t.nack(ctx, d, tracker, handlerReason(err))
+	}
+	b.success()
+	return t.ack(ctx, d, tracker)
+}
+
+// reject 丢弃无法解析或校验失败的消息，这类消息重新入队也不会成功
+func (t *taskConsumer) reject(ctx context.Context, d amqp.Delivery, tracker *tagTracker, reason RejectReason) error {
+	if t.AutoAck {
+		t.rejected(ctx, reason)
+		return nil
+	}
+	// 当requeue为true时，将该消息排队，以在另一个通道上传递给使用者。
+	// 当requeue为false或服务器无法将该消息排队时，它将被丢弃。
+	if err := d.Reject(false); err != nil {
+		return err
+	}
+	t.rejected(ctx, reason)
+	return tracker.forget(d.DeliveryTag)
+}
+
+// requeue returns the delivery not handled to the broker, so that it is redelivered to another consumer
+func (t *taskConsumer) requeue(ctx context.Context, d amqp.Delivery, tracker *tagTracker) error {
+	if t.AutoAck {
+		return nil
+	}
+	if err := d.Nack(false, true); err != nil {
+		return err
+	}
+	t.rejected(ctx, RejectShutdown)
+	return tracker.forget(d.DeliveryTag)
+}
+
+// nack 否定确认执行失败的消息，开启NackMultiple时交由tracker批量发送
+func (t *taskConsumer) nack(ctx context.Context, d amqp.Delivery, tracker *tagTracker, reason RejectReason) error {
+	if t.AutoAck {
+		t.rejected(ctx, reason)
+		return nil
+	}
+	if t.NackMultiple {
+		if err := tracker.settle(d.DeliveryTag, outcomeNack); err != nil {
 			return err
 		}
+		t.rejected(ctx, reason)
+		return nil
+	}
+	if err := d.Reject(t.Requeue); err != nil {
+		return err
+	}
+	t.rejected(ctx, reason)
+	return tracker.forget(d.DeliveryTag)
+}
+
+func (t *taskConsumer) ack(ctx context.Context, d amqp.Delivery, tracker *tagTracker) error {
+	if t.AutoAck {
+		t.acked(ctx)
 		return nil
 	}
 	// 手动确认收到本条消息, true表示回复当前信道所有未回复的ack，用于批量确认。
 	// false表示回复当前条目
-	return d.Ack(false)
+	if err := d.Ack(false); err != nil {
+		return err
+	}
+	t.acked(ctx)
+	return tracker.forget(d.DeliveryTag)
+}
+
+// metadataHeaders returns the headers without the ones that aren't strings and can't be converted to metadata,
+// i.e. x-delay kept by the delayed message plugin and the deadline, the headers of the delivery stay untouched
+func metadataHeaders(headers amqp.Table) amqp.Table {
+	_, delay := headers[delayHeader]
+	_, deadline := headers[notValidAfterHeader]
+	if !delay && !deadline {
+		return headers
+	}
+	result := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		if k == delayHeader || k == notValidAfterHeader {
+			continue
+		}
+		result[k] = v
+	}
+	return result
 }
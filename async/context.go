@@ -0,0 +1,46 @@
+package async
+
+import (
+	"context"
+
+	"github.com/streadway/amqp"
+)
+
+type priorityKey struct{}
+
+type deliveryKey struct{}
+
+type uuidKey struct{}
+
+// DeliveryFrom returns the raw delivery of the message being handled, nil if not in a handler.
+// It is read only, and the consumer acks it after the handler returns,
+// so do not Ack, Nack or Reject it in the handler.
+func DeliveryFrom(ctx context.Context) *amqp.Delivery {
+	d, _ := ctx.Value(deliveryKey{}).(*amqp.Delivery)
+	return d
+}
+
+func withDelivery(ctx context.Context, d *amqp.Delivery) context.Context {
+	return context.WithValue(ctx, deliveryKey{}, d)
+}
+
+// PriorityFrom returns the priority of the message being handled, 0 if not set.
+func PriorityFrom(ctx context.Context) uint8 {
+	p, _ := ctx.Value(priorityKey{}).(uint8)
+	return p
+}
+
+func withPriority(ctx context.Context, priority uint8) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// UUIDFrom returns the uuid of the message being handled, the one logged as uuid,
+// e.g. as the idempotency key. It is false if not in a handler or the message has no uuid.
+func UUIDFrom(ctx context.Context) (string, bool) {
+	uuid, ok := ctx.Value(uuidKey{}).(string)
+	return uuid, ok && uuid != ""
+}
+
+func withUUID(ctx context.Context, uuid string) context.Context {
+	return context.WithValue(ctx, uuidKey{}, uuid)
+}
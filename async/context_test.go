@@ -0,0 +1,98 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/logger"
+	"github.com/crochee/lirity/mq"
+)
+
+// ctxTest 记录执行时的context
+type ctxTest struct {
+	ctx chan context.Context
+}
+
+func (c *ctxTest) SafeCopy() Executor {
+	return c
+}
+
+func (c *ctxTest) ID() string {
+	return ""
+}
+
+func (c *ctxTest) Run(ctx context.Context, data []byte) error {
+	c.ctx <- ctx
+	return nil
+}
+
+// consumeOne 发布param并由consumer处理，返回执行时的context
+func consumeOne(t *testing.T, param *Param, opts ...func(*PublishOption)) context.Context {
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	executor := &ctxTest{ctx: make(chan context.Context, 1)}
+	tc := NewTaskConsumer(context.Background())
+	if err := tc.Register(executor); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTaskProducer().Publish(context.Background(), c, "test", param, opts...); err != nil {
+		t.Fatal(err)
+	}
+	if err := tc.handle(context.Background(), <-c.deliveries, newTagTracker(false), nil); err != nil {
+		t.Fatal(err)
+	}
+	return <-executor.ctx
+}
+
+func TestPriorityFrom(t *testing.T) {
+	assert.Equal(t, uint8(0), PriorityFrom(context.Background()))
+	ctx := consumeOne(t, &Param{Name: "async.ctxTest"}, WithPriority(9))
+	assert.Equal(t, uint8(9), PriorityFrom(ctx))
+}
+
+func TestUUIDFrom(t *testing.T) {
+	_, ok := UUIDFrom(context.Background())
+	assert.False(t, ok)
+	ctx := consumeOne(t, &Param{Name: "async.ctxTest"})
+	uuid, ok := UUIDFrom(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, DeliveryFrom(ctx).Headers[mq.DefaultMessageUUIDHeaderKey], uuid)
+}
+
+func TestDeliveryFrom(t *testing.T) {
+	assert.Nil(t, DeliveryFrom(context.Background()))
+	ctx := consumeOne(t, &Param{Name: "async.ctxTest"})
+	d := DeliveryFrom(ctx)
+	if assert.NotNil(t, d) {
+		assert.False(t, d.Redelivered)
+		assert.NotEmpty(t, d.Body)
+	}
+}
+
+type tenantKey struct{}
+
+func TestWithContextExtractor(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	executor := &ctxTest{ctx: make(chan context.Context, 1)}
+	tc := NewTaskConsumer(context.Background(), WithContextExtractor(func(ctx context.Context, d amqp.Delivery) context.Context {
+		tenant, _ := d.Headers["x-tenant-id"].(string)
+		return logger.WithField(context.WithValue(ctx, tenantKey{}, tenant), "tenant_id", tenant)
+	}))
+	assert.NoError(t, tc.Register(executor))
+	ctx := logger.With(context.Background(), zap.New(core))
+	assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+		Acknowledger: mockAck{},
+		Headers:      amqp.Table{"x-tenant-id": "t1"},
+		Body:         []byte(`{"name":"async.ctxTest"}`),
+	}))
+	assert.Equal(t, "t1", (<-executor.ctx).Value(tenantKey{}))
+	entries := logs.FilterMessageSnippet("consume").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "t1", entries[0].ContextMap()["tenant_id"])
+	}
+}
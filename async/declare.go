@@ -0,0 +1,80 @@
+package async
+
+import (
+	"errors"
+
+	"github.com/streadway/amqp"
+)
+
+// ErrDeclareNotSupported is returned by Subscribe with DeclareQueue if the Channel is not a QueueDeclarer.
+var ErrDeclareNotSupported = errors.New("channel does not support declaring queues")
+
+// QueueDeclarer is a Channel able to declare queues, like *amqp.Channel.
+type QueueDeclarer interface {
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+}
+
+// WithDeclareQueue declares the durable queue with QueueArgs before consuming it.
+func WithDeclareQueue() func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.DeclareQueue = true
+	}
+}
+
+// WithDeadLetter sets the dead letter exchange and routing key of the queue declared by WithDeclareQueue,
+// an empty routingKey keeps the one of the message.
+// Only the messages rejected without requeue are dead lettered, that is the ones failing to decode
+// or validate, and the failed ones unless Requeue, so with Requeue a retried message never reaches it.
+func WithDeadLetter(exchange, routingKey string) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		if o.QueueArgs == nil {
+			o.QueueArgs = make(amqp.Table)
+		}
+		o.QueueArgs["x-dead-letter-exchange"] = exchange
+		if routingKey != "" {
+			o.QueueArgs["x-dead-letter-routing-key"] = routingKey
+		}
+	}
+}
+
+// WithQueueArgs merges args into QueueArgs of the queue declared by WithDeclareQueue, for the x- arguments
+// without a dedicated option like x-message-ttl or x-max-length. A later option overrides the same key,
+// either WithQueueArgs or a typed one like WithDeadLetter.
+func WithQueueArgs(args amqp.Table) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.QueueArgs = mergeArgs(o.QueueArgs, args)
+	}
+}
+
+// mergeArgs copies args into dst, allocating it if nil, so that args can be reused by the caller
+func mergeArgs(dst, args amqp.Table) amqp.Table {
+	if dst == nil {
+		dst = make(amqp.Table, len(args))
+	}
+	for key, value := range args {
+		dst[key] = value
+	}
+	return dst
+}
+
+func (t *taskConsumer) declare(channel Channel, queueName string) error {
+	if !t.DeclareQueue {
+		return nil
+	}
+	declarer, ok := channel.(QueueDeclarer)
+	if !ok {
+		return ErrDeclareNotSupported
+	}
+	_, err := declarer.QueueDeclare(
+		queueName,
+		// 持久化
+		true,
+		// 不自动删除
+		false,
+		// 非排他
+		false,
+		false,
+		t.QueueArgs,
+	)
+	return err
+}
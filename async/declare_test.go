@@ -0,0 +1,99 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type declareChannel struct {
+	mockChannel
+	mutex sync.Mutex
+	name  string
+	args  amqp.Table
+}
+
+func (d *declareChannel) QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool,
+	args amqp.Table) (amqp.Queue, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.name, d.args = name, args
+	return amqp.Queue{Name: name}, nil
+}
+
+func (d *declareChannel) declared() (string, amqp.Table) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.name, d.args
+}
+
+func TestWithDeadLetter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &declareChannel{}
+	tc := NewTaskConsumer(ctx, WithDeclareQueue(), WithDeadLetter("dlx", "task.dead"))
+	_, err := tc.Subscribe(c, "task")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		name, _ := c.declared()
+		return name == "task"
+	}, time.Second, 10*time.Millisecond)
+	_, args := c.declared()
+	assert.Equal(t, amqp.Table{"x-dead-letter-exchange": "dlx", "x-dead-letter-routing-key": "task.dead"}, args)
+
+	_, err = NewTaskConsumer(ctx, WithDeclareQueue()).Subscribe(&mockChannel{}, "task")
+	assert.Equal(t, ErrDeclareNotSupported, err)
+}
+
+func TestWithArgs(t *testing.T) {
+	args := amqp.Table{"x-message-ttl": int32(60000), "x-dead-letter-exchange": "old"}
+	tc := NewTaskConsumer(context.Background(),
+		WithQueueArgs(args),
+		WithDeadLetter("dlx", ""),
+		WithQueueArgs(amqp.Table{"x-max-length": int32(100)}),
+		WithConsumeArgs(amqp.Table{"x-priority": int32(10), "x-stream-offset": "last"}),
+		WithStreamOffset("first"),
+	)
+	assert.Equal(t, amqp.Table{
+		"x-message-ttl":          int32(60000),
+		"x-dead-letter-exchange": "dlx",
+		"x-max-length":           int32(100),
+	}, tc.QueueArgs)
+	assert.Equal(t, amqp.Table{"x-priority": int32(10), "x-stream-offset": "first"}, tc.ConsumeArgs)
+	// 不修改调用方的参数
+	assert.Equal(t, "old", args["x-dead-letter-exchange"])
+}
+
+// 只有不重新入队的拒绝才会进入死信交换机，Requeue时重试的消息不会到达
+func TestDeadLetterRequeue(t *testing.T) {
+	tests := []struct {
+		name    string
+		requeue bool
+		body    string
+		want    ackCall
+	}{
+		{name: "poison dead lettered", requeue: true, body: `{`, want: ackCall{method: "reject", tag: 1}},
+		{name: "failure dead lettered", body: `{"name":"async.testError"}`, want: ackCall{method: "reject", tag: 1}},
+		{
+			name:    "failure requeued",
+			requeue: true,
+			body:    `{"name":"async.testError"}`,
+			want:    ackCall{method: "reject", tag: 1, requeue: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &recordAck{}
+			tc := NewTaskConsumer(context.Background(), WithDeclareQueue(), WithDeadLetter("dlx", ""),
+				func(o *ConsumerOption) { o.Requeue = tt.requeue })
+			assert.NoError(t, tc.Register(testError{}))
+			assert.NoError(t, tc.HandleDelivery(context.Background(),
+				amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: []byte(tt.body)}))
+			assert.Equal(t, []ackCall{tt.want}, ack.calls)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/streadway/amqp"
+)
+
+// delayHeader is the header of the delayed message plugin, it stays on the delivery
+const delayHeader = "x-delay"
+
+// ErrDelayNotConfigured is returned when a delayed message is published without a DelayMode.
+var ErrDelayNotConfigured = errors.New("delayed publishing is not configured")
+
+// DelayMode is how the broker delays a message.
+type DelayMode uint8
+
+const (
+	// DelayNone does not support delayed messages
+	DelayNone DelayMode = iota
+	// DelayPlugin uses the rabbitmq-delayed-message-exchange plugin,
+	// Exchange must be declared with type x-delayed-message
+	DelayPlugin
+	// DelayTTL publishes to DelayExchange with a per message expiration,
+	// its queue must dead letter to Exchange with x-dead-letter-exchange.
+	// Note that rabbitmq only expires messages at the head of a queue,
+	// so a message with a shorter delay waits for the longer ones published before it.
+	DelayTTL
+)
+
+// WithDelayPlugin delays messages with the x-delay header of the delayed message plugin.
+func WithDelayPlugin() func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.DelayMode = DelayPlugin
+	}
+}
+
+// WithDelayTTL delays messages by publishing to the exchange whose queue dead letters to Exchange.
+func WithDelayTTL(exchange string) func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.DelayMode = DelayTTL
+		o.DelayExchange = exchange
+	}
+}
+
+// WithDelay delays the message, it requires the DelayMode of the producer.
+func WithDelay(delay time.Duration) func(*PublishOption) {
+	return func(o *PublishOption) {
+		o.Delay = delay
+	}
+}
+
+// PublishDelayed publishes the param to be consumed after delay.
+func (t *TaskProducer) PublishDelayed(ctx context.Context, channel Channel, routingKey string, param *Param,
+	delay time.Duration, opts ...func(*PublishOption)) error {
+	// 复制opts，不写入调用方切片的剩余容量
+	return t.Publish(ctx, channel, routingKey, param,
+		append(append(make([]func(*PublishOption), 0, len(opts)+1), opts...), WithDelay(delay))...)
+}
+
+// delay sets the delay of msg and returns the exchange to publish to instead of exchange
+func (t *TaskProducer) delay(msg *amqp.Publishing, exchange string, delay time.Duration) (string, error) {
+	if delay <= 0 {
+		return exchange, nil
+	}
+	ms := delay.Milliseconds()
+	switch t.DelayMode {
+	case DelayPlugin:
+		if msg.Headers == nil {
+			msg.Headers = make(amqp.Table)
+		}
+		msg.Headers[delayHeader] = ms
+		return exchange, nil
+	case DelayTTL:
+		msg.Expiration = strconv.FormatInt(ms, 10)
+		return t.DelayExchange, nil
+	default:
+		return "", ErrDelayNotConfigured
+	}
+}
@@ -0,0 +1,80 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// captureChannel 记录最后一次发布
+type captureChannel struct {
+	mockChannel
+	exchange string
+	key      string
+	msg      amqp.Publishing
+}
+
+func (c *captureChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	c.exchange, c.key, c.msg = exchange, key, msg
+	return nil
+}
+
+func TestPublishDelayed(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []func(*ProducerOption)
+		wantErr      error
+		wantExchange string
+		wantDelay    interface{}
+		wantExpire   string
+	}{
+		{name: "not configured", wantErr: ErrDelayNotConfigured},
+		{
+			name:         "plugin",
+			opts:         []func(*ProducerOption){WithDelayPlugin()},
+			wantExchange: "dcs.api.async",
+			wantDelay:    int64(300000),
+		},
+		{
+			name:         "ttl",
+			opts:         []func(*ProducerOption){WithDelayTTL("dcs.api.async.delay")},
+			wantExchange: "dcs.api.async.delay",
+			wantExpire:   "300000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &captureChannel{}
+			err := NewTaskProducer(tt.opts...).PublishDelayed(context.Background(), c, "test",
+				&Param{Name: "async.test"}, 5*time.Minute)
+			assert.Equal(t, tt.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tt.wantExchange, c.exchange)
+			assert.Equal(t, "test", c.key)
+			assert.Equal(t, tt.wantDelay, c.msg.Headers["x-delay"])
+			assert.Equal(t, tt.wantExpire, c.msg.Expiration)
+		})
+	}
+
+	// 不写入opts的剩余容量
+	opts := make([]func(*PublishOption), 0, 1)
+	assert.NoError(t, NewTaskProducer(WithDelayPlugin()).PublishDelayed(context.Background(), &captureChannel{}, "test",
+		&Param{Name: "async.test"}, time.Second, opts...))
+	assert.Nil(t, opts[:1][0])
+}
+
+func TestConsumeDelayed(t *testing.T) {
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	executor := &ctxTest{ctx: make(chan context.Context, 1)}
+	tc := NewTaskConsumer(context.Background())
+	assert.NoError(t, tc.Register(executor))
+	assert.NoError(t, NewTaskProducer(WithDelayPlugin()).PublishDelayed(context.Background(), c, "test",
+		&Param{Name: "async.ctxTest"}, time.Second))
+	assert.NoError(t, tc.handle(context.Background(), <-c.deliveries, newTagTracker(false), nil))
+	assert.Len(t, executor.ctx, 1)
+}
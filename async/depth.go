@@ -0,0 +1,41 @@
+package async
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrNotSubscribed is returned by QueueDepth for a queue without a running Subscription.
+	ErrNotSubscribed = errors.New("queue is not subscribed")
+	// ErrInspectNotSupported is returned by QueueDepth if the Channel is not a QueueInspector.
+	ErrInspectNotSupported = errors.New("channel does not support queue inspect")
+)
+
+// QueueInspector is a Channel able to inspect a queue passively, like *amqp.Channel.
+type QueueInspector interface {
+	QueueInspect(name string) (amqp.Queue, error)
+}
+
+// QueueDepth returns how many messages of queueName are ready on the broker, not counting the ones
+// delivered and not acked yet, which are Stats.InFlight or still in the prefetch buffer.
+// With InFlight it is meant for an external scaler like KEDA, and is safe to call periodically:
+// the passive inspect runs on the channel of the Subscription, which the deliveries don't wait for.
+// Only the subscribed queues are inspected, since inspecting a missing queue closes the channel.
+func (t *taskConsumer) QueueDepth(queueName string) (int, error) {
+	s, ok := t.queueSubscription(queueName)
+	if !ok {
+		return 0, ErrNotSubscribed
+	}
+	inspector, ok := s.current().(QueueInspector)
+	if !ok {
+		return 0, ErrInspectNotSupported
+	}
+	queue, err := inspector.QueueInspect(queueName)
+	if err != nil {
+		return 0, fmt.Errorf("cann't inspect queue %s,%w", queueName, err)
+	}
+	return queue.Messages, nil
+}
@@ -0,0 +1,47 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type inspectChannel struct {
+	cancelChannel
+	messages int
+	err      error
+}
+
+func (c *inspectChannel) QueueInspect(name string) (amqp.Queue, error) {
+	return amqp.Queue{Name: name, Messages: c.messages}, c.err
+}
+
+func TestQueueDepth(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+		queue   string
+		want    int
+		wantErr error
+	}{
+		{name: "depth", channel: &inspectChannel{messages: 42}, queue: "a", want: 42},
+		{name: "inspect failed", channel: &inspectChannel{err: amqp.ErrClosed}, queue: "a", wantErr: amqp.ErrClosed},
+		{name: "not subscribed", channel: &inspectChannel{}, queue: "b", wantErr: ErrNotSubscribed},
+		{name: "not supported", channel: &cancelChannel{}, queue: "a", wantErr: ErrInspectNotSupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tc := NewTaskConsumer(ctx)
+			_, err := tc.Subscribe(tt.channel, "a")
+			assert.NoError(t, err)
+			depth, err := tc.QueueDepth(tt.queue)
+			assert.True(t, errors.Is(err, tt.wantErr), err)
+			assert.Equal(t, tt.want, depth)
+		})
+	}
+}
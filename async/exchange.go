@@ -0,0 +1,21 @@
+package async
+
+import "context"
+
+// WithExchange publishes the message to exchange instead of the Exchange of the producer,
+// an empty exchange keeps the latter. Nothing is declared, the exchange and its bindings must exist.
+// With DelayTTL a delayed message still goes to DelayExchange, which dead letters to a fixed exchange.
+func WithExchange(exchange string) func(*PublishOption) {
+	return func(o *PublishOption) {
+		o.Exchange = exchange
+	}
+}
+
+// PublishTo publishes the param to exchange with routingKey, e.g. to a topic exchange fanning it out
+// to the queues bound by pattern, as Publish does with the confirm and marshal options of the producer.
+func (t *TaskProducer) PublishTo(ctx context.Context, channel Channel, exchange, routingKey string, param *Param,
+	opts ...func(*PublishOption)) error {
+	// 复制opts，不写入调用方切片的剩余容量
+	return t.Publish(ctx, channel, routingKey, param,
+		append(append(make([]func(*PublishOption), 0, len(opts)+1), opts...), WithExchange(exchange))...)
+}
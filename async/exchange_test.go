@@ -0,0 +1,61 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishTo(t *testing.T) {
+	param := &Param{Name: "async.test"}
+	tests := []struct {
+		name         string
+		opts         []func(*ProducerOption)
+		publish      []func(*PublishOption)
+		exchange     string
+		wantExchange string
+	}{
+		{name: "topic", exchange: "events", wantExchange: "events"},
+		{name: "empty keeps producer", wantExchange: "dcs.api.async"},
+		{
+			name:         "delay plugin",
+			opts:         []func(*ProducerOption){WithDelayPlugin()},
+			publish:      []func(*PublishOption){WithDelay(time.Second)},
+			exchange:     "events.delayed",
+			wantExchange: "events.delayed",
+		},
+		{
+			name:         "delay ttl",
+			opts:         []func(*ProducerOption){WithDelayTTL("events.delay")},
+			publish:      []func(*PublishOption){WithDelay(time.Second)},
+			exchange:     "events",
+			wantExchange: "events.delay",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &captureChannel{}
+			tp := NewTaskProducer(tt.opts...)
+			assert.NoError(t, tp.PublishTo(context.Background(), c, tt.exchange, "order.created", param, tt.publish...))
+			assert.Equal(t, tt.wantExchange, c.exchange)
+			assert.Equal(t, "order.created", c.key)
+		})
+	}
+
+	// 不写入opts的剩余容量
+	opts := make([]func(*PublishOption), 0, 1)
+	assert.NoError(t, NewTaskProducer().PublishTo(context.Background(), &captureChannel{}, "events", "order.created",
+		param, opts...))
+	assert.Nil(t, opts[:1][0])
+
+	t.Run("confirm", func(t *testing.T) {
+		c := &confirmChannel{reply: func(tag uint64) (amqp.Confirmation, bool) {
+			return amqp.Confirmation{DeliveryTag: tag}, true
+		}}
+		tp := NewTaskProducer(WithConfirms())
+		assert.Equal(t, ErrNack, tp.PublishTo(context.Background(), c, "events", "order.created", param))
+	})
+}
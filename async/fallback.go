@@ -0,0 +1,59 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDefaultNotSupported is returned by RegisterDefault if the Manager is not a DefaultManager.
+var ErrDefaultNotSupported = errors.New("manager does not support a default handler")
+
+// DefaultManager is a ManagerExecutor running a catch-all handler for the unregistered task names,
+// like the one of NewManager.
+type DefaultManager interface {
+	RegisterDefault(f func(ctx context.Context, name string, data []byte) error)
+}
+
+// RegisterDefault sets f to handle the messages of the unregistered task names, e.g. to log them
+// or dead letter them by returning an error without Requeue, while a new task rolls out before
+// every consumer knows it. The data belongs to the pooled Param like the one of Executor.Run.
+// Without it such a message fails like a handler error.
+func (t *taskConsumer) RegisterDefault(f func(ctx context.Context, name string, data []byte) error) error {
+	m, ok := t.Manager.(DefaultManager)
+	if !ok {
+		return ErrDefaultNotSupported
+	}
+	m.RegisterDefault(f)
+	return nil
+}
+
+func (m *manager) RegisterDefault(f func(ctx context.Context, name string, data []byte) error) {
+	m.defaultHandler = f
+}
+
+// fallback returns the default handler as the executor of name
+func (m *manager) fallback(name string) (Executor, error) {
+	if m.defaultHandler == nil {
+		return nil, fmt.Errorf("must register model %s", name)
+	}
+	return defaultExecutor{name: name, f: m.defaultHandler}, nil
+}
+
+// defaultExecutor runs the default handler for the task name
+type defaultExecutor struct {
+	name string
+	f    func(ctx context.Context, name string, data []byte) error
+}
+
+func (d defaultExecutor) SafeCopy() Executor {
+	return d
+}
+
+func (d defaultExecutor) ID() string {
+	return d.name
+}
+
+func (d defaultExecutor) Run(ctx context.Context, data []byte) error {
+	return d.f(ctx, d.name, data)
+}
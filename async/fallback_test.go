@@ -0,0 +1,52 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegisterDefault(t *testing.T) {
+	tests := []struct {
+		name   string
+		result error
+		want   []ackCall
+	}{
+		{name: "handled", want: []ackCall{{method: "ack", tag: 1}}},
+		// 不重新入队时拒绝，进入死信队列
+		{name: "dead lettered", result: errors.New("unknown task"), want: []ackCall{{method: "reject", tag: 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := NewTaskConsumer(context.Background())
+			var got []string
+			assert.NoError(t, tc.RegisterDefault(func(ctx context.Context, name string, data []byte) error {
+				got = append(got, name+":"+string(data))
+				return tt.result
+			}))
+			assert.NoError(t, tc.Register(nopTest{}))
+			for _, body := range []string{`{"name":"async.nopTest"}`, `{"name":"async.newTask","data":"e30="}`} {
+				ack := &recordAck{}
+				assert.NoError(t, tc.HandleDelivery(context.Background(), amqp.Delivery{
+					Acknowledger: ack,
+					DeliveryTag:  1,
+					Body:         []byte(body),
+				}))
+				if body == `{"name":"async.nopTest"}` {
+					assert.Equal(t, []ackCall{{method: "ack", tag: 1}}, ack.calls)
+					continue
+				}
+				assert.Equal(t, tt.want, ack.calls)
+			}
+			assert.Equal(t, []string{"async.newTask:{}"}, got)
+		})
+	}
+
+	tc := NewTaskConsumer(context.Background())
+	tc.Manager = plainManager{tc.Manager}
+	assert.ErrorIs(t, tc.RegisterDefault(func(ctx context.Context, name string, data []byte) error { return nil }),
+		ErrDefaultNotSupported)
+}
@@ -0,0 +1,66 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// ErrProducerClosed is returned by Publish after CloseContext.
+var ErrProducerClosed = errors.New("producer is closed")
+
+// flushInterval is how often Flush checks the messages in flight
+const flushInterval = 10 * time.Millisecond
+
+// UnconfirmedError is returned by Flush when ctx is done before all the messages are published,
+// Count is the number of messages still publishing or waiting for the confirmation.
+type UnconfirmedError struct {
+	Count int64
+	Err   error
+}
+
+func (u *UnconfirmedError) Error() string {
+	return fmt.Sprintf("%d messages unconfirmed,%v", u.Count, u.Err)
+}
+
+func (u *UnconfirmedError) Unwrap() error {
+	return u.Err
+}
+
+// Flush blocks until the Publish calls in progress return, which includes waiting
+// for their confirmations with WithConfirms, or returns *UnconfirmedError once ctx is done.
+func (t *TaskProducer) Flush(ctx context.Context) error {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+	for {
+		if t.inFlight.Load() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return &UnconfirmedError{Count: t.inFlight.Load(), Err: ctx.Err()}
+		case <-ticker.C:
+		}
+	}
+}
+
+// CloseContext stops publishing, flushes the messages in flight within ctx,
+// then closes the channels put in confirm mode by the producer.
+// The other channels given to Publish are owned by the caller and left open.
+func (t *TaskProducer) CloseContext(ctx context.Context) error {
+	t.mutex.Lock()
+	t.closed = true
+	t.mutex.Unlock()
+	err := t.Flush(ctx)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for channel := range t.confirmers {
+		err = multierr.Append(err, channel.Close())
+		delete(t.confirmers, channel)
+	}
+	return err
+}
@@ -0,0 +1,55 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// closeConfirmChannel 记录是否被关闭
+type closeConfirmChannel struct {
+	confirmChannel
+	closed bool
+}
+
+func (c *closeConfirmChannel) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestProducerFlush(t *testing.T) {
+	tp := NewTaskProducer(WithConfirms())
+	release := make(chan struct{})
+	c := &closeConfirmChannel{}
+	c.reply = func(tag uint64) (amqp.Confirmation, bool) {
+		go func() {
+			<-release
+			c.notify <- amqp.Confirmation{DeliveryTag: tag, Ack: true}
+		}()
+		return amqp.Confirmation{}, false
+	}
+	published := make(chan error, 1)
+	go func() {
+		published <- tp.Publish(context.Background(), c, "test", &Param{Name: "async.test"})
+	}()
+	assert.Eventually(t, func() bool { return tp.inFlight.Load() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var unconfirmed *UnconfirmedError
+	err := tp.Flush(ctx)
+	if assert.True(t, errors.As(err, &unconfirmed)) {
+		assert.Equal(t, int64(1), unconfirmed.Count)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	}
+
+	close(release)
+	assert.NoError(t, tp.CloseContext(context.Background()))
+	assert.NoError(t, <-published)
+	assert.True(t, c.closed)
+	assert.Equal(t, ErrProducerClosed, tp.Publish(context.Background(), c, "test", &Param{Name: "async.test"}))
+}
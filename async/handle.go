@@ -7,6 +7,9 @@ import (
 	"sync"
 )
 
+// ParamPool recycles the Param of each message.
+// Put must reset the Param by Param.Reset before returning it to the pool,
+// otherwise the fields of one message may leak into the next.
 type ParamPool interface {
 	Get() *Param
 	Put(*Param)
@@ -17,7 +20,9 @@ type ManagerExecutor interface {
 	Run(ctx context.Context, param *Param) error
 }
 
-// Executor your business should implement it
+// Executor your business should implement it.
+// The data passed to Run belongs to a pooled Param which is reused once Run returns,
+// so it must not be kept, e.g. in the error by ErrorCode.WithResult, use WithResultCopy instead.
 type Executor interface {
 	SafeCopy() Executor
 	ID() string
@@ -28,49 +33,75 @@ type Param struct {
 	Name     string                 `json:"name" binding:"required"`
 	Metadata map[string]interface{} `json:"metadata"`
 	Data     []byte                 `json:"data"`
+	// Version selects the executor registered by RegisterVersioned, 0 runs the one of Register
+	Version int `json:"version,omitempty"`
 }
 
+// Reset zeroes the Param but keeps the allocated Metadata and Data for reuse.
+func (p *Param) Reset() {
+	p.Name = ""
+	p.Version = 0
+	if p.Metadata == nil {
+		p.Metadata = make(map[string]interface{})
+	}
+	for key := range p.Metadata {
+		delete(p.Metadata, key)
+	}
+	p.Data = p.Data[:0]
+}
+
+// NewParamPool returns a ParamPool backed by sync.Pool, it also implements StatsParamPool
 func NewParamPool() ParamPool {
-	return &defaultParamPool{pool: sync.Pool{New: func() interface{} {
-		return &Param{
-			Name:     "",
-			Metadata: make(map[string]interface{}),
-			Data:     make([]byte, 0),
-		}
-	}}}
+	d := &defaultParamPool{}
+	d.pool.New = func() interface{} {
+		d.stats.miss()
+		return newParam()
+	}
+	return d
+}
+
+func newParam() *Param {
+	return &Param{
+		Name:     "",
+		Metadata: make(map[string]interface{}),
+		Data:     make([]byte, 0),
+	}
 }
 
 type defaultParamPool struct {
-	pool sync.Pool
+	pool  sync.Pool
+	stats poolCounter
 }
 
 func (d *defaultParamPool) Get() *Param {
+	d.stats.get()
 	v, ok := d.pool.Get().(*Param)
 	if !ok {
-		return &Param{
-			Name:     "",
-			Metadata: make(map[string]interface{}),
-			Data:     make([]byte, 0),
-		}
+		d.stats.miss()
+		return newParam()
 	}
 	return v
 }
 
 func (d *defaultParamPool) Put(param *Param) {
-	param.Name = ""
-	for key := range param.Metadata {
-		delete(param.Metadata, key)
-	}
-	param.Data = param.Data[:0]
+	param.Reset()
+	d.stats.put()
 	d.pool.Put(param)
 }
 
+func (d *defaultParamPool) PoolStats() PoolStats {
+	return d.stats.snapshot()
+}
+
 func NewManager() ManagerExecutor {
-	return &manager{model: make(map[string]Executor)}
+	return &manager{model: make(map[string]Executor), versioned: make(map[versionKey]Executor)}
 }
 
 type manager struct {
-	model map[string]Executor
+	model     map[string]Executor
+	versioned map[versionKey]Executor
+	// defaultHandler 处理未注册的任务，见RegisterDefault
+	defaultHandler func(ctx context.Context, name string, data []byte) error
 }
 
 func (m *manager) register(v Executor) error {
@@ -103,9 +134,9 @@ func (m *manager) Register(executors ...Executor) error {
 }
 
 func (m *manager) Run(ctx context.Context, param *Param) error {
-	v, ok := m.model[param.Name]
-	if !ok {
-		return fmt.Errorf("must register model %s", param.Name)
+	v, err := m.executor(param)
+	if err != nil {
+		return err
 	}
 	return v.SafeCopy().Run(ctx, param.Data)
 }
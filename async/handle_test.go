@@ -8,6 +8,8 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/crochee/lirity/routine"
 )
 
@@ -120,3 +122,36 @@ func TestRetry(t *testing.T) {
 		Data: nil,
 	}))
 }
+
+func TestParamPoolReset(t *testing.T) {
+	pool := NewParamPool()
+	param := pool.Get()
+	param.Name = "async.test"
+	param.Metadata["retry"] = 1
+	param.Data = append(param.Data, `{"id":1}`...)
+	pool.Put(param)
+
+	next := pool.Get()
+	if next.Name != "" || len(next.Metadata) != 0 || len(next.Data) != 0 {
+		t.Fatalf("param leaked from the previous message: %#v", next)
+	}
+}
+
+func TestPoolStats(t *testing.T) {
+	pool := NewBoundedParamPool(1)
+	first, second := pool.Get(), pool.Get()
+	pool.Put(first)
+	pool.Put(second)
+	assert.Same(t, first, pool.Get())
+	assert.Equal(t, PoolStats{Gets: 3, Misses: 2, Puts: 2, Dropped: 1}, pool.PoolStats())
+	assert.Equal(t, int64(1), pool.PoolStats().Hits())
+
+	stats, ok := NewParamPool().(StatsParamPool)
+	if assert.True(t, ok) {
+		stats.Put(stats.Get())
+		got := stats.PoolStats()
+		assert.Equal(t, int64(1), got.Gets)
+		assert.Equal(t, int64(1), got.Misses)
+		assert.Equal(t, int64(1), got.Puts)
+	}
+}
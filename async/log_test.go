@@ -0,0 +1,105 @@
+package async
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/logger"
+)
+
+func TestHandleLogFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx, cancel := context.WithCancel(logger.With(context.Background(), zap.New(core)))
+	defer cancel()
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	tc := NewTaskConsumer(ctx)
+	assert.NoError(t, tc.Register(test{}))
+	assert.NoError(t, NewTaskProducer().Publish(ctx, c, "task", &Param{Name: "async.test"}))
+	_, err := tc.Subscribe(c, "task")
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return logs.FilterMessageSnippet("consume").Len() == 1 }, time.Second,
+		10*time.Millisecond)
+	fields := logs.FilterMessageSnippet("consume").All()[0].ContextMap()
+	assert.Equal(t, "task", fields["queue"])
+	assert.Equal(t, "consumer.task", fields["consumer_tag"])
+	assert.Equal(t, uint64(0), fields["delivery_tag"])
+	assert.Equal(t, false, fields["redelivered"])
+	assert.NotEmpty(t, fields["uuid"])
+}
+
+type nopTest struct{}
+
+func (n nopTest) SafeCopy() Executor {
+	return n
+}
+
+func (n nopTest) ID() string {
+	return ""
+}
+
+func (n nopTest) Run(ctx context.Context, data []byte) error {
+	return nil
+}
+
+func BenchmarkHandle(b *testing.B) {
+	ctx := logger.With(context.Background(), logger.Nop())
+	tc := NewTaskConsumer(ctx)
+	if err := tc.Register(nopTest{}); err != nil {
+		b.Fatal(err)
+	}
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	if err := NewTaskProducer().Publish(ctx, c, "task", &Param{Name: "async.nopTest"}); err != nil {
+		b.Fatal(err)
+	}
+	d := <-c.deliveries
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := tc.HandleDelivery(ctx, d); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestWithPayloadRedactor(t *testing.T) {
+	tests := []struct {
+		name  string
+		level zapcore.Level
+		want  []string
+		calls int
+	}{
+		{name: "debug", level: zapcore.DebugLevel, want: []string{`{"name":"async.nopTest","secret":"***"}`}, calls: 1},
+		{name: "info", level: zapcore.InfoLevel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(tt.level)
+			ctx := logger.With(context.Background(), zap.New(core))
+			var calls int
+			tc := NewTaskConsumer(ctx, WithPayloadRedactor(func(payload []byte) []byte {
+				calls++
+				return []byte(strings.Replace(string(payload), "s3cret", "***", 1))
+			}))
+			assert.NoError(t, tc.Register(nopTest{}))
+			assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+				Acknowledger: mockAck{},
+				Body:         []byte(`{"name":"async.nopTest","secret":"s3cret"}`),
+			}))
+			var bodies []string
+			for _, entry := range logs.FilterMessage("consume body").All() {
+				bodies = append(bodies, entry.ContextMap()["body"].(string))
+			}
+			assert.Equal(t, tt.want, bodies)
+			assert.Equal(t, tt.calls, calls)
+		})
+	}
+}
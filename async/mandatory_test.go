@@ -0,0 +1,82 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// returnChannel 将路由键为unbound的mandatory消息先退回再确认，与rabbitmq的顺序一致
+type returnChannel struct {
+	confirmChannel
+	returns chan amqp.Return
+}
+
+func (r *returnChannel) Publish(exchange, key string, mandatory, immediate bool, msg amqp.Publishing) error {
+	if mandatory && key == "unbound" {
+		r.returns <- amqp.Return{ReplyCode: 312, ReplyText: "NO_ROUTE", MessageId: msg.MessageId}
+	}
+	return r.confirmChannel.Publish(exchange, key, mandatory, immediate, msg)
+}
+
+func (r *returnChannel) NotifyReturn(c chan amqp.Return) chan amqp.Return {
+	r.returns = c
+	return c
+}
+
+func TestPublishMandatory(t *testing.T) {
+	param := &Param{Name: "async.test"}
+	ack := func(tag uint64) (amqp.Confirmation, bool) {
+		return amqp.Confirmation{DeliveryTag: tag, Ack: true}, true
+	}
+	tests := []struct {
+		name    string
+		channel Channel
+		opts    []func(*ProducerOption)
+		key     string
+		want    error
+	}{
+		{
+			name:    "routed",
+			channel: &returnChannel{confirmChannel: confirmChannel{reply: ack}},
+			opts:    []func(*ProducerOption){WithConfirms()},
+			key:     "bound",
+		},
+		{
+			name:    "unroutable",
+			channel: &returnChannel{confirmChannel: confirmChannel{reply: ack}},
+			opts:    []func(*ProducerOption){WithConfirms()},
+			key:     "unbound",
+			want:    ErrUnroutable,
+		},
+		{
+			name:    "without confirm",
+			channel: &returnChannel{confirmChannel: confirmChannel{reply: ack}},
+			key:     "unbound",
+			want:    ErrMandatoryWithoutConfirm,
+		},
+		{
+			name:    "not supported",
+			channel: &confirmChannel{reply: ack},
+			opts:    []func(*ProducerOption){WithConfirms()},
+			key:     "unbound",
+			want:    ErrReturnNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := NewTaskProducer(tt.opts...)
+			for i := 0; i < 3; i++ {
+				err := tp.Publish(context.Background(), tt.channel, tt.key, param, WithMandatory())
+				if tt.want == nil {
+					assert.NoError(t, err)
+					continue
+				}
+				assert.True(t, errors.Is(err, tt.want), err)
+			}
+		})
+	}
+}
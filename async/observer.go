@@ -0,0 +1,102 @@
+package async
+
+import (
+	"context"
+	"errors"
+)
+
+// RejectReason is why a delivery is rejected, its values are bounded to be used as a metrics label.
+type RejectReason uint8
+
+const (
+	// RejectUnmarshalEnvelope is the amqp message failing to unmarshal by Marshal
+	RejectUnmarshalEnvelope RejectReason = iota + 1
+	// RejectUnmarshalPayload is the payload failing to decode into Param
+	RejectUnmarshalPayload
+	// RejectValidationFailed is Param failing to validate
+	RejectValidationFailed
+	// RejectHandlerError is the executor returning an error
+	RejectHandlerError
+	// RejectTimeout is the executor returning context.DeadlineExceeded
+	RejectTimeout
+	// RejectShutdown is the delivery requeued since the consumer stopped before handling it
+	RejectShutdown
+	// RejectContentEncoding is the body of an unsupported ContentEncoding or failing to decompress
+	RejectContentEncoding
+	// RejectUnknownVersion is Param.Version without a registered executor
+	RejectUnknownVersion
+	// RejectStale is the message past its deadline, it is acked without handling, see WithNotValidAfter
+	RejectStale
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectUnmarshalEnvelope:
+		return "unmarshal_envelope"
+	case RejectUnmarshalPayload:
+		return "unmarshal_payload"
+	case RejectValidationFailed:
+		return "validation_failed"
+	case RejectHandlerError:
+		return "handler_error"
+	case RejectTimeout:
+		return "timeout"
+	case RejectShutdown:
+		return "shutdown"
+	case RejectContentEncoding:
+		return "content_encoding"
+	case RejectUnknownVersion:
+		return "unknown_version"
+	case RejectStale:
+		return "stale"
+	default:
+		return "unknown"
+	}
+}
+
+// handlerReason classifies the error of the executor
+func handlerReason(err error) RejectReason {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return RejectTimeout
+	}
+	return RejectHandlerError
+}
+
+// Observer is notified of how each delivery is settled, e.g. to count metrics by queue and reason.
+// queue is empty for the deliveries handled by HandleDelivery.
+type Observer interface {
+	Acked(queue string)
+	Rejected(queue string, reason RejectReason)
+}
+
+// WithObserver sets the Observer of the consumer.
+func WithObserver(o Observer) func(*ConsumerOption) {
+	return func(opt *ConsumerOption) {
+		opt.Observer = o
+	}
+}
+
+type queueKey struct{}
+
+// QueueFrom returns the name of the queue the message being handled is consumed from.
+func QueueFrom(ctx context.Context) string {
+	queue, _ := ctx.Value(queueKey{}).(string)
+	return queue
+}
+
+func withQueue(ctx context.Context, queue string) context.Context {
+	return context.WithValue(ctx, queueKey{}, queue)
+}
+
+func (t *taskConsumer) acked(ctx context.Context) {
+	t.stats.acked()
+	if t.Observer != nil {
+		t.Observer.Acked(QueueFrom(ctx))
+	}
+}
+
+func (t *taskConsumer) rejected(ctx context.Context, reason RejectReason) {
+	if t.Observer != nil {
+		t.Observer.Rejected(QueueFrom(ctx), reason)
+	}
+}
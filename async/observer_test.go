@@ -0,0 +1,120 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/mq"
+)
+
+type recordObserver struct {
+	mutex    sync.Mutex
+	acked    []string
+	rejected []RejectReason
+}
+
+func (r *recordObserver) Acked(queue string) {
+	r.mutex.Lock()
+	r.acked = append(r.acked, queue)
+	r.mutex.Unlock()
+}
+
+func (r *recordObserver) Rejected(queue string, reason RejectReason) {
+	r.mutex.Lock()
+	r.rejected = append(r.rejected, reason)
+	r.mutex.Unlock()
+}
+
+type timeoutTest struct{}
+
+func (d timeoutTest) SafeCopy() Executor {
+	return d
+}
+
+func (d timeoutTest) ID() string {
+	return ""
+}
+
+func (d timeoutTest) Run(ctx context.Context, data []byte) error {
+	return fmt.Errorf("call downstream,%w", context.DeadlineExceeded)
+}
+
+func TestObserverRejectReason(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers amqp.Table
+		body    string
+		want    RejectReason
+	}{
+		{
+			name:    "envelope",
+			headers: amqp.Table{mq.DefaultMessageUUIDHeaderKey: 1},
+			body:    `{"name":"async.test"}`,
+			want:    RejectUnmarshalEnvelope,
+		},
+		{name: "payload", body: `{`, want: RejectUnmarshalPayload},
+		{name: "validation", body: `{}`, want: RejectValidationFailed},
+		{name: "handler", body: `{"name":"async.testError"}`, want: RejectHandlerError},
+		{name: "timeout", body: `{"name":"async.timeoutTest"}`, want: RejectTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(o))
+			assert.NoError(t, tc.Register(test{}, testError{}, timeoutTest{}))
+			ctx := withQueue(context.Background(), "task")
+			assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+				Acknowledger: mockAck{},
+				Headers:      tt.headers,
+				Body:         []byte(tt.body),
+			}))
+			assert.Equal(t, []RejectReason{tt.want}, o.rejected)
+			assert.Empty(t, o.acked)
+		})
+	}
+
+	o := &recordObserver{}
+	tc := NewTaskConsumer(context.Background(), WithObserver(o))
+	assert.NoError(t, tc.Register(test{}))
+	assert.NoError(t, tc.HandleDelivery(withQueue(context.Background(), "task"),
+		amqp.Delivery{Acknowledger: mockAck{}, Body: []byte(`{"name":"async.test"}`)}))
+	assert.Equal(t, []string{"task"}, o.acked)
+	assert.Equal(t, "timeout", RejectTimeout.String())
+	assert.Equal(t, "unknown", RejectReason(0).String())
+}
+
+// errAck fails every acknowledgement
+type errAck struct{}
+
+func (errAck) Ack(tag uint64, multiple bool) error {
+	return amqp.ErrClosed
+}
+
+func (errAck) Nack(tag uint64, multiple bool, requeue bool) error {
+	return amqp.ErrClosed
+}
+
+func (errAck) Reject(tag uint64, requeue bool) error {
+	return amqp.ErrClosed
+}
+
+func TestObserverNackFailed(t *testing.T) {
+	for _, multiple := range []bool{false, true} {
+		t.Run(fmt.Sprintf("multiple %t", multiple), func(t *testing.T) {
+			o := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(o))
+			tc.NackMultiple = multiple
+			assert.NoError(t, tc.Register(testError{}))
+			err := tc.HandleDelivery(withQueue(context.Background(), "task"),
+				amqp.Delivery{Acknowledger: errAck{}, DeliveryTag: 1, Body: []byte(`{"name":"async.testError"}`)})
+			assert.ErrorIs(t, err, amqp.ErrClosed)
+			// 否定确认失败时不通知Observer
+			assert.Empty(t, o.rejected)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package async
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is the error of a handler that panicked, the delivery is nacked
+// as with any handler error instead of being left unacked.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("handler panic: %v", p.Value)
+}
+
+// run is Manager.Run recovering the panic of the handler
+func (t *taskConsumer) run(ctx context.Context, param *Param) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			t.recover(ctx, r, stack)
+			err = &PanicError{Value: r, Stack: stack}
+		}
+	}()
+	return t.Manager.Run(ctx, param)
+}
@@ -0,0 +1,129 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/logger"
+)
+
+type panicTest struct{}
+
+func (p panicTest) SafeCopy() Executor {
+	return p
+}
+
+func (p panicTest) ID() string {
+	return ""
+}
+
+func (p panicTest) Run(ctx context.Context, data []byte) error {
+	panic("boom")
+}
+
+func TestHandlePanic(t *testing.T) {
+	tests := []struct {
+		name    string
+		requeue bool
+	}{
+		{name: "reject"},
+		{name: "requeue", requeue: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ack := &recordAck{}
+			o := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(o))
+			tc.Requeue = tt.requeue
+			assert.NoError(t, tc.Register(panicTest{}))
+			assert.NotPanics(t, func() {
+				assert.NoError(t, tc.HandleDelivery(context.Background(), amqp.Delivery{
+					Acknowledger: ack,
+					DeliveryTag:  1,
+					Body:         []byte(`{"name":"async.panicTest"}`),
+				}))
+			})
+			assert.Equal(t, []ackCall{{method: "reject", tag: 1, requeue: tt.requeue}}, ack.calls)
+			assert.Equal(t, []RejectReason{RejectHandlerError}, o.rejected)
+		})
+	}
+
+	tc := NewTaskConsumer(context.Background())
+	assert.NoError(t, tc.Register(panicTest{}))
+	var pe *PanicError
+	if assert.True(t, errors.As(tc.run(context.Background(), &Param{Name: "async.panicTest"}), &pe)) {
+		assert.Equal(t, "boom", pe.Value)
+		assert.NotEmpty(t, pe.Stack)
+	}
+}
+
+func TestWithRecoverHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    func(record func(string) func(context.Context, interface{})) []func(*ConsumerOption)
+		want    []string
+		wantLog int
+	}{
+		{
+			name: "compose",
+			opts: func(record func(string) func(context.Context, interface{})) []func(*ConsumerOption) {
+				return []func(*ConsumerOption){WithRecoverHandler(record("a")), WithRecoverHandler(record("b"))}
+			},
+			want:    []string{"a:boom", "b:boom"},
+			wantLog: 1,
+		},
+		{
+			name: "replace",
+			opts: func(record func(string) func(context.Context, interface{})) []func(*ConsumerOption) {
+				return []func(*ConsumerOption){WithRecoverHandlerOnly(record("a"))}
+			},
+			want: []string{"a:boom"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.ErrorLevel)
+			ctx := logger.With(context.Background(), zap.New(core))
+			var got []string
+			record := func(name string) func(context.Context, interface{}) {
+				return func(ctx context.Context, r interface{}) { got = append(got, fmt.Sprintf("%s:%v", name, r)) }
+			}
+			tc := NewTaskConsumer(ctx, tt.opts(record)...)
+			assert.NoError(t, tc.Register(panicTest{}))
+			assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+				Acknowledger: mockAck{},
+				Body:         []byte(`{"name":"async.panicTest"}`),
+			}))
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.wantLog, logs.FilterMessage("recover").Len())
+		})
+	}
+}
+
+func TestWithRecoverLevel(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	ctx := logger.With(context.Background(), zap.New(core))
+	tc := NewTaskConsumer(ctx, WithRecoverLevel(func(r interface{}) zapcore.Level {
+		if r == "boom" {
+			return zapcore.WarnLevel
+		}
+		return zapcore.ErrorLevel
+	}))
+	assert.NoError(t, tc.Register(panicTest{}))
+	assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+		Acknowledger: mockAck{},
+		Body:         []byte(`{"name":"async.panicTest"}`),
+	}))
+	entries := logs.FilterMessage("recover").All()
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	}
+}
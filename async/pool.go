@@ -0,0 +1,79 @@
+package async
+
+import "sync/atomic"
+
+// PoolStats counts the use of a ParamPool, Misses is the number of Params allocated by Get,
+// so Gets-Misses are the ones reused. Dropped is the number of Params that the
+// bounded pool had no room for, sync.Pool drops them silently at GC instead.
+type PoolStats struct {
+	Gets    int64 `json:"gets"`
+	Misses  int64 `json:"misses"`
+	Puts    int64 `json:"puts"`
+	Dropped int64 `json:"dropped"`
+}
+
+// Hits returns the number of Params reused by Get
+func (p PoolStats) Hits() int64 {
+	return p.Gets - p.Misses
+}
+
+// StatsParamPool is a ParamPool reporting its PoolStats,
+// like the ones of NewParamPool and NewBoundedParamPool.
+type StatsParamPool interface {
+	ParamPool
+	PoolStats() PoolStats
+}
+
+type poolCounter struct {
+	gets, misses, puts, dropped atomic.Int64
+}
+
+func (c *poolCounter) get()  { c.gets.Add(1) }
+func (c *poolCounter) miss() { c.misses.Add(1) }
+func (c *poolCounter) put()  { c.puts.Add(1) }
+func (c *poolCounter) drop() { c.dropped.Add(1) }
+
+func (c *poolCounter) snapshot() PoolStats {
+	return PoolStats{
+		Gets:    c.gets.Load(),
+		Misses:  c.misses.Load(),
+		Puts:    c.puts.Load(),
+		Dropped: c.dropped.Load(),
+	}
+}
+
+// NewBoundedParamPool returns a ParamPool keeping at most size idle Params,
+// unlike sync.Pool they are not released at GC, the Params over size are dropped by Put.
+func NewBoundedParamPool(size int) StatsParamPool {
+	return &boundedParamPool{params: make(chan *Param, size)}
+}
+
+type boundedParamPool struct {
+	params chan *Param
+	stats  poolCounter
+}
+
+func (b *boundedParamPool) Get() *Param {
+	b.stats.get()
+	select {
+	case param := <-b.params:
+		return param
+	default:
+		b.stats.miss()
+		return newParam()
+	}
+}
+
+func (b *boundedParamPool) Put(param *Param) {
+	param.Reset()
+	b.stats.put()
+	select {
+	case b.params <- param:
+	default:
+		b.stats.drop()
+	}
+}
+
+func (b *boundedParamPool) PoolStats() PoolStats {
+	return b.stats.snapshot()
+}
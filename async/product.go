@@ -3,14 +3,18 @@ package async
 import (
 	"context"
 	"fmt"
-
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
-	"github.com/json-iterator/go"
 	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel/trace"
 
+	"github.com/crochee/lirity/codec"
+	"github.com/crochee/lirity/e"
 	"github.com/crochee/lirity/mq"
 	"github.com/crochee/lirity/validator"
 )
@@ -18,14 +22,56 @@ import (
 type ProducerOption struct {
 	Marshal     mq.MarshalAPI
 	Exchange    string
-	JSONHandler jsoniter.API
+	JSONHandler codec.JSON // codec.Default of encoding/json, or jsoniter.Default of codec/jsoniter
 	ParamPool   ParamPool
 	Validator   validator.Validator
+	// Confirm 是否开启发布确认，需要Channel实现ConfirmChannel
+	Confirm        bool
+	ConfirmTimeout time.Duration
+	// DelayMode 延迟消息的实现方式，DelayTTL时消息发往DelayExchange
+	DelayMode     DelayMode
+	DelayExchange string
+	// Gzip 压缩payload并设置ContentEncoding，见WithGzip
+	Gzip bool
+	// TracePropagation 发布时创建span并注入headers，见WithTracePropagation
+	TracePropagation bool
+}
+
+// PublishOption is the option of each published message.
+type PublishOption struct {
+	// Priority works on the queue declared with x-max-priority, larger is more urgent
+	Priority uint8
+	// Delay is how long the message is delayed, see DelayMode
+	Delay time.Duration
+	// Exchange overrides the Exchange of the producer, see WithExchange
+	Exchange string
+	// Mandatory makes the unroutable message returned, see WithMandatory
+	Mandatory bool
+	// NotValidAfter is the deadline after which the message is dropped, see WithNotValidAfter
+	NotValidAfter time.Time
+}
+
+// WithPriority sets the priority of the message, it is surfaced to handlers by PriorityFrom.
+func WithPriority(priority uint8) func(*PublishOption) {
+	return func(o *PublishOption) {
+		o.Priority = priority
+	}
+}
+
+// exchange returns the Exchange of the message, or the one of the producer if it is empty
+func (o *PublishOption) exchange(producer string) string {
+	if o.Exchange != "" {
+		return o.Exchange
+	}
+	return producer
 }
 
 type TaskProducer struct {
 	ProducerOption
-	wg sync.WaitGroup
+	inFlight   atomic.Int64 // 正在发布或等待确认的消息数
+	mutex      sync.Mutex
+	closed     bool
+	confirmers map[Channel]*confirmer
 }
 
 // NewProducer return message.Publisher
@@ -34,7 +80,7 @@ func NewTaskProducer(opts ...func(*ProducerOption)) *TaskProducer {
 		ProducerOption: ProducerOption{
 			Marshal:     mq.DefaultMarshal{},
 			Exchange:    "dcs.api.async",
-			JSONHandler: jsoniter.ConfigCompatibleWithStandardLibrary,
+			JSONHandler: codec.Default,
 			ParamPool:   NewParamPool(),
 			Validator:   validator.NewValidator(),
 		},
@@ -45,11 +91,22 @@ func NewTaskProducer(opts ...func(*ProducerOption)) *TaskProducer {
 	return t
 }
 
-func (t *TaskProducer) Publish(ctx context.Context, channel Channel, routingKey string, param *Param) error {
-	t.wg.Add(1)
-	defer t.wg.Done()
+func (t *TaskProducer) Publish(ctx context.Context, channel Channel, routingKey string, param *Param,
+	opts ...func(*PublishOption)) error {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return ErrProducerClosed
+	}
+	t.inFlight.Add(1)
+	t.mutex.Unlock()
+	defer t.inFlight.Add(-1)
+	var o PublishOption
+	for _, opt := range opts {
+		opt(&o)
+	}
 	if err := t.Validator.ValidateStruct(param); err != nil {
-		return err
+		return e.FromValidationError(err)
 	}
 	data, err := t.JSONHandler.Marshal(param)
 	if err != nil {
@@ -62,24 +119,96 @@ func (t *TaskProducer) Publish(ctx context.Context, channel Channel, routingKey
 	if amqpMsg, err = t.Marshal.Marshal(message.NewMessage(uuid, data)); err != nil {
 		return fmt.Errorf("cann't marshal message,%w", err)
 	}
+	if t.Gzip {
+		if amqpMsg.Body, err = compress(amqpMsg.Body); err != nil {
+			return fmt.Errorf("cann't compress message,%w", err)
+		}
+		amqpMsg.ContentEncoding = EncodingGzip
+	}
+	amqpMsg.Priority = o.Priority
+	setNotValidAfter(&amqpMsg, o.NotValidAfter)
+	if o.Mandatory {
+		if !t.Confirm {
+			return ErrMandatoryWithoutConfirm
+		}
+		if amqpMsg.MessageId == "" {
+			amqpMsg.MessageId = uuid
+		}
+	}
+	var exchange string
+	if exchange, err = t.delay(&amqpMsg, o.exchange(t.Exchange), o.Delay); err != nil {
+		return err
+	}
+	if t.TracePropagation {
+		var span trace.Span
+		ctx, span = startPublishSpan(ctx, exchange, routingKey, &amqpMsg)
+		err = t.send(ctx, channel, exchange, routingKey, o.Mandatory, amqpMsg)
+		endSpan(span, err)
+		return err
+	}
+	return t.send(ctx, channel, exchange, routingKey, o.Mandatory, amqpMsg)
+}
+
+// send publishes msg on channel, and waits for the confirmation in confirm mode
+func (t *TaskProducer) send(ctx context.Context, channel Channel, exchange, routingKey string, mandatory bool,
+	msg amqp.Publishing) error {
+	if t.Confirm {
+		c, err := t.confirmer(channel)
+		if err != nil {
+			return err
+		}
+		return c.publish(ctx, t.ConfirmTimeout, exchange, routingKey, mandatory, false, msg)
+	}
 	// 发送消息到队列中
 	return channel.Publish(
-		t.Exchange,
+		exchange,
 		routingKey,
 		// 如果为true，根据exchange类型和routekey类型，如果无法找到符合条件的队列，name会把发送的信息返回给发送者
 		false,
 		// 如果为true，当exchange发送到消息队列后发现队列上没有绑定的消费者,则会将消息返还给发送者
 		false,
 		// 发送信息
-		amqpMsg,
+		msg,
 	)
 }
 
+// confirmer returns the confirmer of the channel, it is recreated once the channel is closed.
+// The confirmers are looked up by the channel, so it must be comparable, like a pointer.
+func (t *TaskProducer) confirmer(channel Channel) (*confirmer, error) {
+	confirmChannel, ok := channel.(ConfirmChannel)
+	if !ok {
+		return nil, ErrConfirmNotSupported
+	}
+	// 不可比较的Channel作为map的key会panic
+	if !reflect.ValueOf(channel).Comparable() {
+		return nil, ErrChannelNotComparable
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if c, ok := t.confirmers[channel]; ok {
+		c.mutex.Lock()
+		closed := c.closed
+		c.mutex.Unlock()
+		if !closed {
+			return c, nil
+		}
+	}
+	c, err := newConfirmer(confirmChannel)
+	if err != nil {
+		return nil, err
+	}
+	if t.confirmers == nil {
+		t.confirmers = make(map[Channel]*confirmer)
+	}
+	t.confirmers[channel] = c
+	return c, nil
+}
+
 func (t *TaskProducer) GetParam() *Param {
 	return t.ParamPool.Get()
 }
 
+// Close is CloseContext without a deadline.
 func (t *TaskProducer) Close() error {
-	t.wg.Wait()
-	return nil
+	return t.CloseContext(context.Background())
 }
@@ -0,0 +1,52 @@
+package async
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+)
+
+// WithRateLimit caps the deliveries handled by the consumer to rps per second with bursts of burst,
+// the limiter is shared by all the queues, see WithQueueRateLimit to limit a queue apart.
+// A burst below 1 is 1. Each delivery waits for a token before it is dispatched to the Pool,
+// so the messages over the limit stay unhandled in the prefetch buffer of the channel.
+func WithRateLimit(rps float64, burst int) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.RateLimiter = newLimiter(rps, burst)
+	}
+}
+
+// WithQueueRateLimit caps the deliveries of queue to rps per second with bursts of burst,
+// instead of the limiter of WithRateLimit.
+func WithQueueRateLimit(queue string, rps float64, burst int) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		if o.QueueRateLimiters == nil {
+			o.QueueRateLimiters = make(map[string]*rate.Limiter)
+		}
+		o.QueueRateLimiters[queue] = newLimiter(rps, burst)
+	}
+}
+
+func newLimiter(rps float64, burst int) *rate.Limiter {
+	if burst < 1 {
+		// burst为0时Wait总是失败
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+// limiter returns the limiter of queue, nil if it is not limited
+func (t *taskConsumer) limiter(queue string) *rate.Limiter {
+	if l, ok := t.QueueRateLimiters[queue]; ok {
+		return l
+	}
+	return t.RateLimiter
+}
+
+// wait blocks until l has a token or ctx is done
+func wait(ctx context.Context, l *rate.Limiter) error {
+	if l == nil {
+		return nil
+	}
+	return l.Wait(ctx)
+}
@@ -0,0 +1,78 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// feedChannel 将deliveries的所有消息投递给消费者
+type feedChannel struct {
+	mockChannel
+	deliveries chan amqp.Delivery
+}
+
+func (f *feedChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	return f.deliveries, nil
+}
+
+type countObserver struct {
+	mutex sync.Mutex
+	acked int
+}
+
+func (c *countObserver) Acked(queue string) {
+	c.mutex.Lock()
+	c.acked++
+	c.mutex.Unlock()
+}
+
+func (c *countObserver) Rejected(queue string, reason RejectReason) {}
+
+func (c *countObserver) count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.acked
+}
+
+func TestWithRateLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []func(*ConsumerOption)
+		min  time.Duration
+	}{
+		{name: "shared", opts: []func(*ConsumerOption){WithRateLimit(20, 1)}, min: 150 * time.Millisecond},
+		{
+			name: "queue",
+			opts: []func(*ConsumerOption){WithRateLimit(1, 1), WithQueueRateLimit("task", 20, 1)},
+			min:  150 * time.Millisecond,
+		},
+		{name: "burst", opts: []func(*ConsumerOption){WithRateLimit(1, 5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			o := &countObserver{}
+			tc := NewTaskConsumer(ctx, append(tt.opts, WithObserver(o))...)
+			assert.NoError(t, tc.Register(nopTest{}))
+			c := &feedChannel{deliveries: make(chan amqp.Delivery, 5)}
+			for i := 0; i < 5; i++ {
+				c.deliveries <- amqp.Delivery{Acknowledger: mockAck{}, Body: []byte(`{"name":"async.nopTest"}`)}
+			}
+			start := time.Now()
+			_, err := tc.Subscribe(c, "task")
+			assert.NoError(t, err)
+			assert.Eventually(t, func() bool { return o.count() == 5 }, 2*time.Second, time.Millisecond)
+			assert.GreaterOrEqual(t, time.Since(start), tt.min)
+			if tt.min == 0 {
+				assert.Less(t, time.Since(start), 500*time.Millisecond)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package async
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// WithRecoverHandler adds f to the handling of the panics in the consumer, the ones of the handlers included,
+// e.g. to report them to Sentry. The panics are still logged with their stack before f,
+// and the handlers added earlier are still called.
+func WithRecoverHandler(f func(ctx context.Context, r interface{})) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		if prev := o.RecoverHandler; prev != nil {
+			o.RecoverHandler = func(ctx context.Context, r interface{}) {
+				prev(ctx, r)
+				f(ctx, r)
+			}
+			return
+		}
+		o.RecoverHandler = f
+	}
+}
+
+// WithRecoverHandlerOnly is WithRecoverHandler which replaces the default logging of the panics.
+func WithRecoverHandlerOnly(f func(ctx context.Context, r interface{})) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.RecoverHandler = f
+		o.SkipRecoverLog = true
+	}
+}
+
+// WithRecoverLevel decides the level the panics are logged at by the recovered value, Error by default,
+// e.g. Warn for the expected ones so that they don't fire the Error alerts.
+func WithRecoverLevel(f func(r interface{}) zapcore.Level) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.RecoverLevel = f
+	}
+}
+
+func (t *taskConsumer) recover(ctx context.Context, r interface{}, stack []byte) {
+	if !t.SkipRecoverLog {
+		level := zapcore.ErrorLevel
+		if t.RecoverLevel != nil {
+			level = t.RecoverLevel(r)
+		}
+		if ce := logger.From(ctx).Check(level, "recover"); ce != nil {
+			ce.Write(zap.Any("error", r), zap.ByteString("stack", stack))
+		}
+	}
+	if t.RecoverHandler != nil {
+		t.RecoverHandler(ctx, r)
+	}
+}
@@ -0,0 +1,29 @@
+package async
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// WithPayloadRedactor sets f to scrub the sensitive fields of each payload before it is logged,
+// the payloads are logged as is by default. They are only logged at Debug,
+// f isn't called at all for a higher level, and it must not modify the payload in place.
+func WithPayloadRedactor(f func(payload []byte) []byte) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.PayloadRedactor = f
+	}
+}
+
+func (t *taskConsumer) logPayload(ctx context.Context, payload []byte) {
+	ce := logger.From(ctx).Check(zap.DebugLevel, "consume body")
+	if ce == nil {
+		return
+	}
+	if t.PayloadRedactor != nil {
+		payload = t.PayloadRedactor(payload)
+	}
+	ce.Write(zap.ByteString("body", payload))
+}
@@ -0,0 +1,69 @@
+package async
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/crochee/lirity/e"
+)
+
+// SchemaError is a value of Param.Data failing its JSON Schema, it is the result of e.ErrInvalidParam
+type SchemaError struct {
+	// Location is the JSON pointer of the value in Param.Data, empty for the root
+	Location string `json:"location"`
+	Message  string `json:"message"`
+}
+
+// RegisterSchema registers the JSON Schema validating Param.Data of the task name before it runs,
+// for the payloads not modelled as Go structs. Data failing it is rejected like the struct validation.
+// It is not goroutine safe, call it before Subscribe.
+func (t *taskConsumer) RegisterSchema(name, schema string) error {
+	compiled, err := jsonschema.CompileString(name+".json", schema)
+	if err != nil {
+		return fmt.Errorf("cann't compile schema of %s,%w", name, err)
+	}
+	if t.Schemas == nil {
+		t.Schemas = make(map[string]*jsonschema.Schema)
+	}
+	t.Schemas[name] = compiled
+	return nil
+}
+
+// validateSchema returns e.ErrInvalidParam with the []SchemaError if param.Data fails the schema of its task
+func (t *taskConsumer) validateSchema(param *Param) error {
+	schema, ok := t.Schemas[param.Name]
+	if !ok {
+		return nil
+	}
+	var v interface{}
+	if len(param.Data) > 0 {
+		decoder := json.NewDecoder(bytes.NewReader(param.Data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&v); err != nil {
+			return e.ErrInvalidParam.WithResult([]SchemaError{{Message: err.Error()}})
+		}
+	}
+	err := schema.Validate(v)
+	if err == nil {
+		return nil
+	}
+	ve, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return e.ErrInvalidParam.WithResult([]SchemaError{{Message: err.Error()}})
+	}
+	return e.ErrInvalidParam.WithResult(schemaErrors(ve, nil))
+}
+
+// schemaErrors flattens the leaf causes of ve, which are the actual failures
+func schemaErrors(ve *jsonschema.ValidationError, list []SchemaError) []SchemaError {
+	if len(ve.Causes) == 0 {
+		return append(list, SchemaError{Location: ve.InstanceLocation, Message: ve.Message})
+	}
+	for _, cause := range ve.Causes {
+		list = schemaErrors(cause, list)
+	}
+	return list
+}
@@ -0,0 +1,67 @@
+package async
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/e"
+)
+
+func TestRegisterSchema(t *testing.T) {
+	const schema = `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {"id": {"type": "integer"}, "tags": {"type": "array", "items": {"type": "string"}}}
+	}`
+	tests := []struct {
+		name string
+		data string
+		want []SchemaError
+	}{
+		{name: "valid", data: `{"id":1,"tags":["a"]}`},
+		{
+			name: "missing",
+			data: `{"tags":["a",2]}`,
+			want: []SchemaError{
+				{Location: "", Message: "missing properties: 'id'"},
+				{Location: "/tags/1", Message: "expected string, but got number"},
+			},
+		},
+		{name: "not json", data: `{`, want: []SchemaError{{Message: "unexpected EOF"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(o))
+			assert.NoError(t, tc.Register(nopTest{}))
+			assert.NoError(t, tc.RegisterSchema("async.nopTest", schema))
+			param := &Param{Name: "async.nopTest", Data: []byte(tt.data)}
+
+			err := tc.validateSchema(param)
+			if tt.want == nil {
+				assert.NoError(t, err)
+			} else if ec, ok := e.As(err); assert.True(t, ok, err) {
+				assert.Equal(t, e.ErrInvalidParam.Code(), ec.Code())
+				assert.ElementsMatch(t, tt.want, ec.Result())
+			}
+
+			body, err := json.Marshal(param)
+			assert.NoError(t, err)
+			ack := &recordAck{}
+			assert.NoError(t, tc.HandleDelivery(context.Background(), amqp.Delivery{Acknowledger: ack, DeliveryTag: 1, Body: body}))
+			if tt.want == nil {
+				assert.Equal(t, []ackCall{{method: "ack", tag: 1}}, ack.calls)
+				return
+			}
+			assert.Equal(t, []ackCall{{method: "reject", tag: 1}}, ack.calls)
+			assert.Equal(t, []RejectReason{RejectValidationFailed}, o.rejected)
+		})
+	}
+
+	tc := NewTaskConsumer(context.Background())
+	assert.Error(t, tc.RegisterSchema("async.nopTest", `{"type": 1}`))
+}
@@ -0,0 +1,26 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleShutdown(t *testing.T) {
+	ack := &recordAck{}
+	o := &recordObserver{}
+	tc := NewTaskConsumer(context.Background(), WithObserver(o))
+	assert.NoError(t, tc.Register(test{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, tc.HandleDelivery(ctx, amqp.Delivery{
+		Acknowledger: ack,
+		DeliveryTag:  1,
+		Body:         []byte(`{"name":"async.test"}`),
+	}))
+	assert.Equal(t, []ackCall{{method: "nack", tag: 1, requeue: true}}, ack.calls)
+	assert.Equal(t, []RejectReason{RejectShutdown}, o.rejected)
+	assert.Equal(t, "shutdown", RejectShutdown.String())
+}
@@ -0,0 +1,84 @@
+package async
+
+import (
+	"context"
+	"time"
+
+	"github.com/streadway/amqp"
+	"go.uber.org/zap"
+
+	"github.com/crochee/lirity/logger"
+)
+
+// notValidAfterHeader is the deadline of the message in unix milliseconds
+const notValidAfterHeader = "x-not-valid-after"
+
+// WithNotValidAfter sets the deadline of the message, the consumer acks and drops it without handling
+// once the deadline is passed, e.g. for the tasks only valid for a short time like sending an OTP.
+// A zero deadline means the message never goes stale.
+func WithNotValidAfter(deadline time.Time) func(*PublishOption) {
+	return func(o *PublishOption) {
+		o.NotValidAfter = deadline
+	}
+}
+
+// WithStaleTolerance sets how long after its deadline a message is still handled,
+// to tolerate the clock skew between the producers and the consumers.
+func WithStaleTolerance(tolerance time.Duration) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.StaleTolerance = tolerance
+	}
+}
+
+// setNotValidAfter sets the deadline header of msg
+func setNotValidAfter(msg *amqp.Publishing, deadline time.Time) {
+	if deadline.IsZero() {
+		return
+	}
+	if msg.Headers == nil {
+		msg.Headers = make(amqp.Table)
+	}
+	msg.Headers[notValidAfterHeader] = deadline.UnixMilli()
+}
+
+// notValidAfter reads the deadline header of d
+func notValidAfter(d *amqp.Delivery) (time.Time, bool) {
+	v, ok := d.Headers[notValidAfterHeader]
+	if !ok {
+		return time.Time{}, false
+	}
+	var ms int64
+	switch n := v.(type) {
+	case int64:
+		ms = n
+	case int32:
+		ms = int64(n)
+	default:
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// stale reports whether the deadline of d is passed by more than StaleTolerance
+func (t *taskConsumer) stale(d *amqp.Delivery) (time.Time, bool) {
+	deadline, ok := notValidAfter(d)
+	if !ok {
+		return deadline, false
+	}
+	return deadline, time.Now().After(deadline.Add(t.StaleTolerance))
+}
+
+// dropStale acks the stale delivery without handling it, it is counted as RejectStale by the Observer
+func (t *taskConsumer) dropStale(ctx context.Context, d amqp.Delivery, tracker *tagTracker, deadline time.Time) error {
+	logger.From(ctx).Warn("stale message dropped", zap.Time("not_valid_after", deadline))
+	if t.AutoAck {
+		t.rejected(ctx, RejectStale)
+		return nil
+	}
+	// 确认而不是拒绝，过期消息不应进入死信队列
+	if err := d.Ack(false); err != nil {
+		return err
+	}
+	t.rejected(ctx, RejectStale)
+	return tracker.forget(d.DeliveryTag)
+}
@@ -0,0 +1,60 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithNotValidAfter(t *testing.T) {
+	tests := []struct {
+		name      string
+		deadline  time.Time
+		tolerance time.Duration
+		handled   bool
+	}{
+		{name: "no deadline", handled: true},
+		{name: "valid", deadline: time.Now().Add(time.Hour), handled: true},
+		{name: "stale", deadline: time.Now().Add(-time.Minute)},
+		{name: "within tolerance", deadline: time.Now().Add(-time.Minute), tolerance: time.Hour, handled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+			executor := &ctxTest{ctx: make(chan context.Context, 1)}
+			observer := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(observer), WithStaleTolerance(tt.tolerance))
+			assert.NoError(t, tc.Register(executor))
+			assert.NoError(t, NewTaskProducer().Publish(context.Background(), c, "test",
+				&Param{Name: "async.ctxTest"}, WithNotValidAfter(tt.deadline)))
+			ack := &recordAck{}
+			d := <-c.deliveries
+			d.Acknowledger, d.DeliveryTag = ack, 1
+			if d.Headers == nil {
+				d.Headers = amqp.Table{}
+			}
+			d.Headers[delayHeader] = int32(1000)
+			headers := amqp.Table{}
+			for k, v := range d.Headers {
+				headers[k] = v
+			}
+			assert.NoError(t, tc.HandleDelivery(context.Background(), d))
+			// 投递的headers保持不变
+			assert.Equal(t, headers, d.Headers)
+			// 过期消息被确认而不是拒绝
+			assert.Equal(t, []ackCall{{method: "ack", tag: 1}}, ack.calls)
+			assert.Len(t, executor.ctx, map[bool]int{true: 1}[tt.handled])
+			if tt.handled {
+				assert.Len(t, observer.acked, 1)
+				assert.Empty(t, observer.rejected)
+				return
+			}
+			assert.Empty(t, observer.acked)
+			assert.Equal(t, []RejectReason{RejectStale}, observer.rejected)
+			assert.Equal(t, "stale", RejectStale.String())
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package async
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueueStats is the consuming state of a subscribed queue.
+type QueueStats struct {
+	Queue       string `json:"queue"`
+	ConsumerTag string `json:"consumer_tag"`
+	Consuming   bool   `json:"consuming"`
+}
+
+// Stats is a snapshot of the consumer state, it is serialized by StatsHandler.
+type Stats struct {
+	// Connected reports whether every subscribed queue has a live delivery channel
+	Connected bool         `json:"connected"`
+	Queues    []QueueStats `json:"queues"`
+	// InFlight is the deliveries being handled, with QueueDepth it gives the backlog for autoscaling
+	InFlight  int64  `json:"in_flight"`
+	LastError string `json:"last_error,omitempty"`
+	// LastErrorTime is zero if no error has occurred
+	LastErrorTime time.Time `json:"last_error_time"`
+	// LastAckTime is the time of the last successful ack, zero if nothing is acked yet
+	LastAckTime time.Time `json:"last_ack_time"`
+	// Alive is false once the consumer context is done
+	Alive bool `json:"alive"`
+	// Ready is Alive and Connected
+	Ready bool `json:"ready"`
+}
+
+type consumerStats struct {
+	inFlight      int64 // 保持64位对齐，供atomic使用
+	mutex         sync.RWMutex
+	queues        map[string]*QueueStats
+	lastError     string
+	lastErrorTime time.Time
+	lastAckTime   time.Time
+}
+
+func newConsumerStats() *consumerStats {
+	return &consumerStats{queues: make(map[string]*QueueStats)}
+}
+
+func (s *consumerStats) setQueue(queue, consumerTag string, consuming bool) {
+	s.mutex.Lock()
+	s.queues[queue] = &QueueStats{Queue: queue, ConsumerTag: consumerTag, Consuming: consuming}
+	s.mutex.Unlock()
+}
+
+func (s *consumerStats) setConsuming(queue string, consuming bool) {
+	s.mutex.Lock()
+	if q, ok := s.queues[queue]; ok {
+		q.Consuming = consuming
+	}
+	s.mutex.Unlock()
+}
+
+// removeQueue drops the queue whose subscription has stopped, so that it doesn't count as disconnected
+func (s *consumerStats) removeQueue(queue string) {
+	s.mutex.Lock()
+	delete(s.queues, queue)
+	s.mutex.Unlock()
+}
+
+func (s *consumerStats) addInFlight(delta int64) {
+	atomic.AddInt64(&s.inFlight, delta)
+}
+
+func (s *consumerStats) setError(err error) {
+	s.mutex.Lock()
+	s.lastError = err.Error()
+	s.lastErrorTime = time.Now()
+	s.mutex.Unlock()
+}
+
+func (s *consumerStats) acked() {
+	s.mutex.Lock()
+	s.lastAckTime = time.Now()
+	s.mutex.Unlock()
+}
+
+func (s *consumerStats) snapshot(alive bool) Stats {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stats := Stats{
+		Connected:     len(s.queues) > 0,
+		Queues:        make([]QueueStats, 0, len(s.queues)),
+		InFlight:      atomic.LoadInt64(&s.inFlight),
+		LastError:     s.lastError,
+		LastErrorTime: s.lastErrorTime,
+		LastAckTime:   s.lastAckTime,
+		Alive:         alive,
+	}
+	for _, q := range s.queues {
+		stats.Queues = append(stats.Queues, *q)
+		if !q.Consuming {
+			stats.Connected = false
+		}
+	}
+	sort.Slice(stats.Queues, func(i, j int) bool { return stats.Queues[i].Queue < stats.Queues[j].Queue })
+	stats.Ready = stats.Alive && stats.Connected
+	return stats
+}
+
+// Stats returns a snapshot of the consumer state.
+func (t *taskConsumer) Stats() Stats {
+	return t.stats.snapshot(t.ctx.Err() == nil)
+}
+
+// StatsHandler returns a http.Handler that serializes Stats to JSON.
+// It responds 503 when the consumer is not ready, or not alive if the query has probe=live,
+// so that the same handler serves both the readiness and the liveness probe.
+func (t *taskConsumer) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := t.Stats()
+		ok := stats.Ready
+		if r.URL.Query().Get("probe") == "live" {
+			ok = stats.Alive
+		}
+		data, err := t.JSONHandler.Marshal(stats)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_, _ = w.Write(data)
+	})
+}
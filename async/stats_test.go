@@ -0,0 +1,72 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tc := NewTaskConsumer(ctx)
+	tc.stats.setQueue("b", "consumer.b", true)
+	tc.stats.setQueue("a", "consumer.a", false)
+	tc.stats.setError(errors.New("channel closed"))
+
+	stats := tc.Stats()
+	assert.False(t, stats.Connected)
+	assert.True(t, stats.Alive)
+	assert.False(t, stats.Ready)
+	assert.Equal(t, []QueueStats{
+		{Queue: "a", ConsumerTag: "consumer.a"},
+		{Queue: "b", ConsumerTag: "consumer.b", Consuming: true},
+	}, stats.Queues)
+	assert.Equal(t, "channel closed", stats.LastError)
+
+	tests := []struct {
+		name   string
+		target string
+		setup  func()
+		want   int
+	}{
+		{name: "not ready", target: "/healthz", want: http.StatusServiceUnavailable},
+		{name: "alive", target: "/healthz?probe=live", want: http.StatusOK},
+		{
+			name:   "ready",
+			target: "/healthz",
+			setup:  func() { tc.stats.setConsuming("a", true) },
+			want:   http.StatusOK,
+		},
+		{name: "dead", target: "/healthz?probe=live", setup: cancel, want: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+			w := httptest.NewRecorder()
+			tc.StatsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		})
+	}
+}
+
+func TestWithConsumerTag(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &mockChannel{}
+	tc := NewTaskConsumer(ctx, WithConsumerTag(func(queue string) string {
+		return "pod-0." + queue
+	}))
+	_, err := tc.Subscribe(c, "task")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return c.consumerTag() == "pod-0.task" }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "pod-0.task", tc.Stats().Queues[0].ConsumerTag)
+	assert.Equal(t, "consumer.task", defaultConsumerTag("task"))
+}
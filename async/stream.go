@@ -0,0 +1,45 @@
+package async
+
+import (
+	"errors"
+
+	"github.com/streadway/amqp"
+)
+
+// streamOffsetArg is the Consume argument set by WithStreamOffset
+const streamOffsetArg = "x-stream-offset"
+
+// ErrStreamAutoAck is returned by Subscribe with both WithStreamOffset and WithAutoAck,
+// RabbitMQ requires manual ack to consume a stream queue.
+var ErrStreamAutoAck = errors.New("stream queues cannot be consumed with autoAck")
+
+// WithStreamOffset sets where Subscribe starts consuming a stream queue, offset is one of
+// "first", "last", "next", an int64 offset, a time.Time timestamp, or an interval string like "1h".
+//
+// Stream queues need:
+//   - the queue declared durable with the argument x-queue-type=stream, e.g. by WithDeclareQueue
+//     with QueueArgs, it cannot be exclusive or auto-delete
+//   - a prefetch count set by Qos on the *amqp.Channel, e.g. in a ChannelFactory,
+//     RabbitMQ refuses to consume a stream without it
+//   - manual ack, so it cannot be combined with WithAutoAck, Subscribe returns ErrStreamAutoAck then.
+//     The ack only releases the prefetch credit since a stream message is never removed,
+//     and a nack or reject is ignored
+//
+// The offset is applied to every Consume, so a resubscription after the channel is closed
+// starts from offset again rather than where the consumer stopped.
+func WithStreamOffset(offset interface{}) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		if o.ConsumeArgs == nil {
+			o.ConsumeArgs = make(amqp.Table)
+		}
+		o.ConsumeArgs[streamOffsetArg] = offset
+	}
+}
+
+// WithConsumeArgs merges args into ConsumeArgs passed to every Consume, like x-priority of the consumer.
+// A later option overrides the same key, either WithConsumeArgs or a typed one like WithStreamOffset.
+func WithConsumeArgs(args amqp.Table) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.ConsumeArgs = mergeArgs(o.ConsumeArgs, args)
+	}
+}
@@ -0,0 +1,64 @@
+package async
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+type argsChannel struct {
+	mockChannel
+	mutex sync.Mutex
+	args  amqp.Table
+}
+
+func (a *argsChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.args = args
+	return make(chan amqp.Delivery), nil
+}
+
+func (a *argsChannel) consumed() amqp.Table {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.args
+}
+
+func TestWithStreamOffset(t *testing.T) {
+	ts := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		offset interface{}
+	}{
+		{name: "first", offset: "first"},
+		{name: "offset", offset: int64(100)},
+		{name: "timestamp", offset: ts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			c := &argsChannel{}
+			tc := NewTaskConsumer(ctx, WithStreamOffset(tt.offset))
+			_, err := tc.Subscribe(c, "stream")
+			assert.NoError(t, err)
+			assert.Eventually(t, func() bool { return c.consumed() != nil }, time.Second, 10*time.Millisecond)
+			assert.Equal(t, amqp.Table{"x-stream-offset": tt.offset}, c.consumed())
+			assert.NoError(t, c.consumed().Validate())
+		})
+	}
+}
+
+func TestWithStreamOffsetAutoAck(t *testing.T) {
+	c := &argsChannel{}
+	tc := NewTaskConsumer(context.Background(), WithStreamOffset("first"), WithAutoAck())
+	_, err := tc.Subscribe(c, "stream")
+	assert.ErrorIs(t, err, ErrStreamAutoAck)
+	assert.Nil(t, c.consumed())
+}
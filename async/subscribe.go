@@ -0,0 +1,94 @@
+package async
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/multierr"
+
+	"github.com/crochee/lirity/logger"
+	"github.com/crochee/lirity/mq"
+)
+
+// ChannelFactory opens a Channel, e.g. over a shared connection by NewRabbitmqChannelFactory.
+type ChannelFactory func() (Channel, error)
+
+// WithChannels sets how many channels SubscribeAll opens, 1 by default.
+func WithChannels(n int) func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.Channels = n
+	}
+}
+
+// NewRabbitmqChannelFactory opens the channels over client, closing them keeps client open.
+func NewRabbitmqChannelFactory(client *mq.Client) ChannelFactory {
+	return func() (Channel, error) {
+		channel, err := client.Channel()
+		if err != nil {
+			return nil, fmt.Errorf("cann't open channel,%w", err)
+		}
+		return &rabbitmqChannel{channel: channel}, nil
+	}
+}
+
+// SubscribeAll opens Channels channels by factory, spreads queues across them in turn
+// and consumes like Subscribe without waiting, one Subscription per queue in the order of queues.
+// The channels are closed once all the subscriptions are done. If a queue fails to subscribe,
+// the ones already started are canceled and the channels are closed before returning.
+func (t *taskConsumer) SubscribeAll(factory ChannelFactory, queues ...string) ([]*Subscription, error) {
+	n := t.Channels
+	if n <= 0 {
+		n = 1
+	}
+	if n > len(queues) {
+		n = len(queues)
+	}
+	channels := make([]Channel, 0, n)
+	for i := 0; i < n; i++ {
+		channel, err := factory()
+		if err != nil {
+			return nil, multierr.Append(err, closeChannels(channels))
+		}
+		channels = append(channels, channel)
+	}
+	subscriptions := make([]*Subscription, 0, len(queues))
+	for i, queue := range queues {
+		s, err := t.Subscribe(channels[i%n], queue)
+		if err != nil {
+			// 停止已开始的消费后再关闭信道
+			for _, s := range subscriptions {
+				err = multierr.Append(err, s.Cancel())
+			}
+			for _, s := range subscriptions {
+				<-s.Done()
+			}
+			return nil, multierr.Append(err, closeChannels(channels))
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	started := t.Pool.TryGoUnbounded(func(ctx context.Context) {
+		for _, s := range subscriptions {
+			<-s.Done()
+		}
+		if err := closeChannels(channels); err != nil {
+			logger.From(ctx).Error(err.Error())
+			t.stats.setError(err)
+		}
+	})
+	if !started {
+		// 消费者已停止，订阅的循环均已退出或不会启动
+		for _, s := range subscriptions {
+			<-s.Done()
+		}
+		return nil, multierr.Append(ErrConsumerStopped, closeChannels(channels))
+	}
+	return subscriptions, nil
+}
+
+func closeChannels(channels []Channel) error {
+	var err error
+	for _, channel := range channels {
+		err = multierr.Append(err, channel.Close())
+	}
+	return err
+}
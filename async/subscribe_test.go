@@ -0,0 +1,136 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/routine"
+)
+
+// queueChannel 记录消费的队列和是否关闭
+type queueChannel struct {
+	mockChannel
+	mutex  sync.Mutex
+	queues []string
+	closed bool
+}
+
+func (q *queueChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.queues = append(q.queues, queue)
+	return make(chan amqp.Delivery), nil
+}
+
+func (q *queueChannel) Close() error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.closed = true
+	return nil
+}
+
+func (q *queueChannel) state() ([]string, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return append([]string(nil), q.queues...), q.closed
+}
+
+func TestSubscribeAll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var channels []*queueChannel
+	factory := func() (Channel, error) {
+		c := &queueChannel{}
+		channels = append(channels, c)
+		return c, nil
+	}
+	tc := NewTaskConsumer(ctx, WithChannels(2))
+	subscriptions, err := tc.SubscribeAll(factory, "a", "b", "c")
+	assert.NoError(t, err)
+	if assert.Len(t, subscriptions, 3) {
+		for i, queue := range []string{"a", "b", "c"} {
+			assert.Equal(t, queue, subscriptions[i].Queue())
+		}
+	}
+	assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+	cancel()
+	assert.NoError(t, tc.Wait())
+	if assert.Len(t, channels, 2) {
+		queues, closed := channels[0].state()
+		assert.ElementsMatch(t, []string{"a", "c"}, queues)
+		assert.True(t, closed)
+		queues, closed = channels[1].state()
+		assert.Equal(t, []string{"b"}, queues)
+		assert.True(t, closed)
+	}
+
+	errFactory := errors.New("no connection")
+	_, err = NewTaskConsumer(context.Background()).SubscribeAll(func() (Channel, error) {
+		return nil, errFactory
+	}, "a")
+	assert.Equal(t, errFactory, err)
+
+	// 订阅失败时停止已开始的订阅并关闭信道
+	channels = nil
+	tc = NewTaskConsumer(context.Background())
+	_, err = tc.SubscribeAll(factory, "a", "a")
+	assert.ErrorIs(t, err, ErrConsumerTagInUse)
+	if assert.Len(t, channels, 1) {
+		_, closed := channels[0].state()
+		assert.True(t, closed)
+	}
+	_, ok := tc.Subscription("consumer.a")
+	assert.False(t, ok)
+}
+
+func TestSubscribeSizeOne(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	executor := &ctxTest{ctx: make(chan context.Context, 1)}
+	tc := NewTaskConsumer(ctx)
+	// 消费循环不占用名额，投递仍能在唯一的名额中处理
+	tc.Pool = routine.NewPool(ctx, routine.Size(1))
+	assert.NoError(t, tc.Register(executor))
+	assert.NoError(t, NewTaskProducer().Publish(context.Background(), c, "test", &Param{Name: "async.ctxTest"}))
+	_, err := tc.Subscribe(c, "test")
+	assert.NoError(t, err)
+	select {
+	case <-executor.ctx:
+	case <-time.After(time.Second):
+		t.Fatal("delivery is not handled")
+	}
+	cancel()
+	assert.NoError(t, tc.Pool.WaitTimeout(time.Second))
+}
+
+func TestSubscribeDrain(t *testing.T) {
+	c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+	executor := &ctxTest{ctx: make(chan context.Context, 1)}
+	tc := NewTaskConsumer(context.Background())
+	assert.NoError(t, tc.Register(executor))
+	assert.NoError(t, NewTaskProducer().Publish(context.Background(), c, "test", &Param{Name: "async.ctxTest"}))
+	s, err := tc.Subscribe(c, "test")
+	assert.NoError(t, err)
+	select {
+	case <-executor.ctx:
+	case <-time.After(time.Second):
+		t.Fatal("delivery is not handled")
+	}
+	// 上下文未取消，Drain后消费循环仍须返回
+	tc.Pool.Drain()
+	assert.NoError(t, tc.Pool.WaitTimeout(time.Second))
+	select {
+	case <-s.Done():
+	default:
+		t.Fatal("subscription is not done")
+	}
+	_, err = tc.Subscribe(c, "other")
+	assert.ErrorIs(t, err, ErrConsumerStopped)
+}
@@ -0,0 +1,162 @@
+package async
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/streadway/amqp"
+)
+
+var (
+	// ErrConsumerStopped is returned by Subscribe once the context of the consumer is done.
+	ErrConsumerStopped = errors.New("consumer is stopped")
+	// ErrConsumerTagInUse is returned by Subscribe when the consumer tag of the queue is taken
+	// by a running Subscription, e.g. the same queue subscribed twice, see WithConsumerTag.
+	ErrConsumerTagInUse = errors.New("consumer tag is in use")
+	// errSubscriptionCanceled stops the consume loop of the Subscription canceled before Consume
+	errSubscriptionCanceled = errors.New("subscription is canceled")
+)
+
+// Canceler is a Channel able to cancel a consumer by its tag, like *amqp.Channel.
+type Canceler interface {
+	Cancel(consumer string, noWait bool) error
+}
+
+// Subscription is the consumption of a queue, it stops independently of the other queues.
+type Subscription struct {
+	queue       string
+	consumerTag string
+	channel     Channel
+	// mutex 保证Cancel与Consume互斥，canceled后不再Consume
+	mutex     sync.Mutex
+	canceled  bool
+	consuming bool
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func newSubscription(channel Channel, queue, consumerTag string) *Subscription {
+	return &Subscription{
+		queue:       queue,
+		consumerTag: consumerTag,
+		channel:     channel,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Queue returns the name of the consumed queue
+func (s *Subscription) Queue() string {
+	return s.queue
+}
+
+// ConsumerTag returns the consumer tag of the subscription
+func (s *Subscription) ConsumerTag() string {
+	return s.consumerTag
+}
+
+// Cancel stops consuming the queue, the deliveries already received are still handled.
+// If the Channel is a Canceler the consumer is canceled on the broker as well,
+// otherwise the unhandled deliveries are redelivered once the channel is closed.
+// Canceled before the queue is consumed the Subscription stops without calling Consume,
+// and Cancel is a no-op once it succeeded. If the broker fails to cancel the consumer,
+// the Subscription goes on and Cancel can be retried.
+func (s *Subscription) Cancel() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.canceled {
+		return nil
+	}
+	if canceler, ok := s.channel.(Canceler); ok && s.consuming {
+		// broker未取消时投递通道不会关闭，不标记为已取消
+		if err := canceler.Cancel(s.consumerTag, false); err != nil {
+			return err
+		}
+	}
+	s.canceled = true
+	close(s.stop)
+	return nil
+}
+
+// Done is closed once the subscription has stopped and its deliveries are handled,
+// by Cancel or by the context of the consumer
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// current returns the consumed channel, replaced by CloseHandler after a close
+func (s *Subscription) current() Channel {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.channel
+}
+
+func (s *Subscription) setChannel(channel Channel) {
+	s.mutex.Lock()
+	s.channel = channel
+	s.consuming = false
+	s.mutex.Unlock()
+}
+
+// consume calls f to Consume unless the Subscription is canceled, returning errSubscriptionCanceled then
+func (s *Subscription) consume(f func() (<-chan amqp.Delivery, error)) (<-chan amqp.Delivery, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.canceled {
+		return nil, errSubscriptionCanceled
+	}
+	deliveries, err := f()
+	s.consuming = err == nil
+	return deliveries, err
+}
+
+// stopped is closed by Cancel when the deliveries are not closed by the broker,
+// so that the consume loop doesn't wait for them, nil otherwise
+func (s *Subscription) stopped() <-chan struct{} {
+	if _, ok := s.current().(Canceler); ok {
+		return nil
+	}
+	return s.stop
+}
+
+// Subscription returns the running Subscription of consumerTag,
+// e.g. to cancel one of the queues consumed by SubscribeAll
+func (t *taskConsumer) Subscription(consumerTag string) (*Subscription, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s, ok := t.subscriptions[consumerTag]
+	return s, ok
+}
+
+// queueSubscription returns a running Subscription of queueName
+func (t *taskConsumer) queueSubscription(queueName string) (*Subscription, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, s := range t.subscriptions {
+		if s.queue == queueName {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func (t *taskConsumer) addSubscription(s *Subscription) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.subscriptions[s.consumerTag]; ok {
+		return ErrConsumerTagInUse
+	}
+	if t.subscriptions == nil {
+		t.subscriptions = make(map[string]*Subscription)
+	}
+	t.subscriptions[s.consumerTag] = s
+	return nil
+}
+
+func (t *taskConsumer) removeSubscription(s *Subscription) {
+	t.mutex.Lock()
+	if t.subscriptions[s.consumerTag] == s {
+		delete(t.subscriptions, s.consumerTag)
+	}
+	t.mutex.Unlock()
+}
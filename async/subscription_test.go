@@ -0,0 +1,167 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelChannel 取消消费者时关闭其投递通道
+type cancelChannel struct {
+	mockChannel
+	mutex      sync.Mutex
+	deliveries map[string]chan amqp.Delivery
+	canceled   []string
+	// fail 为Cancel失败的次数
+	fail int
+}
+
+func (c *cancelChannel) Consume(queue, consumer string, autoAck, exclusive, noLocal, noWail bool,
+	args amqp.Table) (<-chan amqp.Delivery, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.deliveries == nil {
+		c.deliveries = make(map[string]chan amqp.Delivery)
+	}
+	d := make(chan amqp.Delivery)
+	c.deliveries[consumer] = d
+	return d, nil
+}
+
+func (c *cancelChannel) Cancel(consumer string, noWait bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.fail > 0 {
+		c.fail--
+		return errors.New("cancel failed")
+	}
+	c.canceled = append(c.canceled, consumer)
+	close(c.deliveries[consumer])
+	return nil
+}
+
+func TestSubscriptionCancel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+	}{
+		{name: "canceler", channel: &cancelChannel{}},
+		{name: "not canceler", channel: &queueChannel{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			tc := NewTaskConsumer(ctx)
+			a, err := tc.Subscribe(tt.channel, "a")
+			assert.NoError(t, err)
+			assert.Equal(t, "a", a.Queue())
+			b, err := tc.Subscribe(tt.channel, "b")
+			assert.NoError(t, err)
+			assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+
+			s, ok := tc.Subscription("consumer.a")
+			assert.True(t, ok)
+			assert.Same(t, a, s)
+			assert.NoError(t, a.Cancel())
+			assert.NoError(t, a.Cancel())
+			select {
+			case <-a.Done():
+			case <-time.After(time.Second):
+				t.Fatal("subscription a is not stopped")
+			}
+			_, ok = tc.Subscription("consumer.a")
+			assert.False(t, ok)
+
+			stats := tc.Stats()
+			assert.True(t, stats.Connected)
+			assert.Equal(t, []QueueStats{{Queue: "b", ConsumerTag: "consumer.b", Consuming: true}}, stats.Queues)
+			if c, ok := tt.channel.(*cancelChannel); ok {
+				assert.Equal(t, []string{"consumer.a"}, c.canceled)
+			}
+
+			cancel()
+			<-b.Done()
+		})
+	}
+}
+
+func TestSubscribeAfterStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	tc := NewTaskConsumer(ctx)
+	cancel()
+	assert.NotPanics(t, func() {
+		_, err := tc.Subscribe(&cancelChannel{}, "a")
+		assert.ErrorIs(t, err, ErrConsumerStopped)
+	})
+	_, ok := tc.Subscription("consumer.a")
+	assert.False(t, ok)
+	assert.Empty(t, tc.Stats().Queues)
+}
+
+func TestSubscriptionCancelBeforeConsume(t *testing.T) {
+	// 在Consume之前取消，不再Consume也不调用broker的Cancel
+	c := &cancelChannel{}
+	s := newSubscription(c, "a", "consumer.a")
+	assert.NoError(t, s.Cancel())
+	_, err := s.consume(func() (<-chan amqp.Delivery, error) {
+		return c.Consume("a", "consumer.a", false, false, false, false, nil)
+	})
+	assert.ErrorIs(t, err, errSubscriptionCanceled)
+	assert.Empty(t, c.canceled)
+	assert.Empty(t, c.deliveries)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := NewTaskConsumer(ctx)
+	s, err = tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	assert.NoError(t, s.Cancel())
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription is not stopped")
+	}
+}
+
+func TestSubscriptionCancelFailed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &cancelChannel{fail: 1}
+	tc := NewTaskConsumer(ctx)
+	s, err := tc.Subscribe(c, "a")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return tc.Stats().Connected }, time.Second, 10*time.Millisecond)
+	// broker取消失败时可重试
+	assert.Error(t, s.Cancel())
+	select {
+	case <-s.Done():
+		t.Fatal("subscription is stopped")
+	case <-time.After(10 * time.Millisecond):
+	}
+	assert.NoError(t, s.Cancel())
+	select {
+	case <-s.Done():
+	case <-time.After(time.Second):
+		t.Fatal("subscription is not stopped")
+	}
+	assert.Equal(t, []string{"consumer.a"}, c.canceled)
+}
+
+func TestSubscribeConsumerTagInUse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc := NewTaskConsumer(ctx)
+	a, err := tc.Subscribe(&cancelChannel{}, "a")
+	assert.NoError(t, err)
+	_, err = tc.Subscribe(&cancelChannel{}, "a")
+	assert.ErrorIs(t, err, ErrConsumerTagInUse)
+	s, ok := tc.Subscription("consumer.a")
+	assert.True(t, ok)
+	assert.Same(t, a, s)
+}
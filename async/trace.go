@@ -0,0 +1,104 @@
+package async
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/crochee/lirity/async"
+
+// WithTracePropagation starts a producer span around each Publish and injects its context into the headers,
+// so that the consumer WithTraceExtraction continues the same trace.
+// The tracer and the propagator are the global ones of otel, see otel.SetTracerProvider and otel.SetTextMapPropagator.
+func WithTracePropagation() func(*ProducerOption) {
+	return func(o *ProducerOption) {
+		o.TracePropagation = true
+	}
+}
+
+// WithTraceExtraction extracts the trace context injected by WithTracePropagation from the headers,
+// and starts a consumer span around the handling of each delivery, the span is in the context of the handlers.
+// It runs before ContextExtractor, so that the trace id is logged with the delivery.
+func WithTraceExtraction() func(*ConsumerOption) {
+	return func(o *ConsumerOption) {
+		o.TracePropagation = true
+	}
+}
+
+// headerCarrier adapts the amqp headers to propagation.TextMapCarrier
+type headerCarrier amqp.Table
+
+func (c headerCarrier) Get(key string) string {
+	v, ok := c[key].(string)
+	if !ok {
+		return ""
+	}
+	return v
+}
+
+func (c headerCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = headerCarrier(nil)
+
+// startPublishSpan starts the producer span and injects it into the headers of msg
+func startPublishSpan(ctx context.Context, exchange, routingKey string, msg *amqp.Publishing) (context.Context, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("%s send", exchange),
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("rabbitmq"),
+			semconv.MessagingDestinationKey.String(exchange),
+			semconv.MessagingRabbitmqRoutingKeyKey.String(routingKey),
+		),
+	)
+	if msg.Headers == nil {
+		msg.Headers = make(amqp.Table)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier(msg.Headers))
+	return ctx, span
+}
+
+// startConsumeSpan starts the consumer span as a child of the context in the headers of d
+func startConsumeSpan(ctx context.Context, d amqp.Delivery) (context.Context, trace.Span) {
+	if d.Headers != nil {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, headerCarrier(d.Headers))
+	}
+	return otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("%s process", d.RoutingKey),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String("rabbitmq"),
+			semconv.MessagingDestinationKey.String(d.Exchange),
+			semconv.MessagingRabbitmqRoutingKeyKey.String(d.RoutingKey),
+			semconv.MessagingOperationProcess,
+		),
+	)
+}
+
+// endSpan records err on span before ending it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		setSpanError(span, err)
+	}
+	span.End()
+}
+
+func setSpanError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
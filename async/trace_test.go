@@ -0,0 +1,54 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagator)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), sc)
+	tests := []struct {
+		name     string
+		producer []func(*ProducerOption)
+		consumer []func(*ConsumerOption)
+		want     trace.TraceID
+	}{
+		{name: "disabled"},
+		{name: "producer only", producer: []func(*ProducerOption){WithTracePropagation()}},
+		{
+			name:     "both sides",
+			producer: []func(*ProducerOption){WithTracePropagation()},
+			consumer: []func(*ConsumerOption){WithTraceExtraction()},
+			want:     sc.TraceID(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockChannel{deliveries: make(chan amqp.Delivery, 1)}
+			executor := &ctxTest{ctx: make(chan context.Context, 1)}
+			tc := NewTaskConsumer(context.Background(), tt.consumer...)
+			assert.NoError(t, tc.Register(executor))
+			assert.NoError(t, NewTaskProducer(tt.producer...).Publish(ctx, c, "test", &Param{Name: "async.ctxTest"}))
+			d := <-c.deliveries
+			_, injected := d.Headers["traceparent"]
+			assert.Equal(t, len(tt.producer) > 0, injected)
+			assert.NoError(t, tc.HandleDelivery(context.Background(), d))
+			assert.Equal(t, tt.want, trace.SpanContextFromContext(<-executor.ctx).TraceID())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package async
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrUnknownVersion is returned by Run for a Param.Version without a registered executor,
+	// such a delivery is rejected as RejectUnknownVersion rather than retried.
+	ErrUnknownVersion = errors.New("unknown task version")
+	// ErrVersionNotSupported is returned by RegisterVersioned if the Manager is not a VersionedManager.
+	ErrVersionNotSupported = errors.New("manager does not support versioned executors")
+)
+
+// VersionedManager is a ManagerExecutor dispatching by Param.Version as well, like the one of NewManager.
+type VersionedManager interface {
+	RegisterVersioned(name string, version int, executor Executor) error
+}
+
+// RegisterVersioned registers executor for version of the task name, so that a breaking change
+// of the payload rolls out as a new version while the consumers still run the old one.
+// The Param without a version, i.e. 0, runs the executor registered by Register.
+func (t *taskConsumer) RegisterVersioned(name string, version int, executor Executor) error {
+	m, ok := t.Manager.(VersionedManager)
+	if !ok {
+		return ErrVersionNotSupported
+	}
+	return m.RegisterVersioned(name, version, executor)
+}
+
+type versionKey struct {
+	name    string
+	version int
+}
+
+func (m *manager) RegisterVersioned(name string, version int, executor Executor) error {
+	if version == 0 {
+		return fmt.Errorf("version of %s must not be 0, use Register", name)
+	}
+	key := versionKey{name: name, version: version}
+	if _, ok := m.versioned[key]; ok {
+		return fmt.Errorf("%s version %d is enable", name, version)
+	}
+	m.versioned[key] = executor
+	return nil
+}
+
+// executor returns the executor of the name and version of param
+func (m *manager) executor(param *Param) (Executor, error) {
+	if param.Version == 0 {
+		v, ok := m.model[param.Name]
+		if !ok {
+			return m.fallback(param.Name)
+		}
+		return v, nil
+	}
+	v, ok := m.versioned[versionKey{name: param.Name, version: param.Version}]
+	if !ok {
+		return nil, fmt.Errorf("%w %d of %s", ErrUnknownVersion, param.Version, param.Name)
+	}
+	return v, nil
+}
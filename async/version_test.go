@@ -0,0 +1,79 @@
+package async
+
+import (
+	"context"
+	"testing"
+
+	"github.com/streadway/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// versionTest 记录运行的版本
+type versionTest struct {
+	version int
+	ran     *[]int
+}
+
+func (v versionTest) SafeCopy() Executor {
+	return v
+}
+
+func (v versionTest) ID() string {
+	return ""
+}
+
+func (v versionTest) Run(ctx context.Context, data []byte) error {
+	*v.ran = append(*v.ran, v.version)
+	return nil
+}
+
+// plainManager 隐藏RegisterVersioned
+type plainManager struct {
+	ManagerExecutor
+}
+
+func TestRegisterVersioned(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		want     []ackCall
+		ran      []int
+		rejected []RejectReason
+	}{
+		{name: "unversioned", body: `{"name":"async.versionTest"}`, want: []ackCall{{method: "ack", tag: 1}}, ran: []int{0}},
+		{name: "v2", body: `{"name":"async.versionTest","version":2}`, want: []ackCall{{method: "ack", tag: 1}}, ran: []int{2}},
+		{
+			name:     "unknown version",
+			body:     `{"name":"async.versionTest","version":3}`,
+			want:     []ackCall{{method: "reject", tag: 1}},
+			rejected: []RejectReason{RejectUnknownVersion},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ran []int
+			o := &recordObserver{}
+			tc := NewTaskConsumer(context.Background(), WithObserver(o))
+			tc.Requeue = true
+			assert.NoError(t, tc.Register(versionTest{ran: &ran}))
+			assert.NoError(t, tc.RegisterVersioned("async.versionTest", 2, versionTest{version: 2, ran: &ran}))
+			ack := &recordAck{}
+			assert.NoError(t, tc.HandleDelivery(context.Background(), amqp.Delivery{
+				Acknowledger: ack,
+				DeliveryTag:  1,
+				Body:         []byte(tt.body),
+			}))
+			assert.Equal(t, tt.want, ack.calls)
+			assert.Equal(t, tt.ran, ran)
+			assert.Equal(t, tt.rejected, o.rejected)
+		})
+	}
+
+	tc := NewTaskConsumer(context.Background())
+	assert.NoError(t, tc.RegisterVersioned("async.versionTest", 1, versionTest{}))
+	assert.Error(t, tc.RegisterVersioned("async.versionTest", 1, versionTest{}))
+	assert.Error(t, tc.RegisterVersioned("async.versionTest", 0, versionTest{}))
+	tc.Manager = plainManager{tc.Manager}
+	assert.ErrorIs(t, tc.RegisterVersioned("async.versionTest", 1, versionTest{}), ErrVersionNotSupported)
+	assert.Equal(t, "unknown_version", RejectUnknownVersion.String())
+}
@@ -0,0 +1,74 @@
+// Package codec abstracts the JSON implementation, encoding/json by default,
+// see the package codec/jsoniter for jsoniter.
+//
+// The packages e and async used jsoniter.ConfigCompatibleWithStandardLibrary before, they use Default now,
+// so that jsoniter isn't linked unless codec/jsoniter is imported. To keep jsoniter, pass jsoniter.Default
+// to e.SetJSON and set it as the JSONHandler of the async producer and consumer options.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// errTrailingData is returned by Number like json.Unmarshal for the data after the top-level value
+var errTrailingData = errors.New("invalid character after top-level value")
+
+// Decoder is implemented by *json.Decoder and *jsoniter.Decoder.
+type Decoder interface {
+	Decode(v interface{}) error
+	UseNumber()
+}
+
+// JSON is the JSON codec used by the packages e and async.
+type JSON interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Default is encoding/json.
+var Default = Std()
+
+// Number is Default which decodes the numbers in interface{} as json.Number instead of float64,
+// so that the int64 ids keep their precision.
+var Number JSON = stdJSON{useNumber: true}
+
+// Std returns the JSON of encoding/json.
+func Std() JSON {
+	return stdJSON{}
+}
+
+type stdJSON struct {
+	useNumber bool
+}
+
+func (stdJSON) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (s stdJSON) Unmarshal(data []byte, v interface{}) error {
+	if !s.useNumber {
+		return json.Unmarshal(data, v)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	// More is false before ] or }, only EOF ends the data
+	if _, err := decoder.Token(); err != io.EOF {
+		return errTrailingData
+	}
+	return nil
+}
+
+func (s stdJSON) NewDecoder(r io.Reader) Decoder {
+	decoder := json.NewDecoder(r)
+	if s.useNumber {
+		decoder.UseNumber()
+	}
+	return decoder
+}
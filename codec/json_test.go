@@ -0,0 +1,54 @@
+package codec
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json JSON
+	}{
+		{name: "default", json: Default},
+		{name: "std", json: Std()},
+		{name: "number", json: Number},
+	}
+	type data struct {
+		ID   uint64 `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := tt.json.Marshal(data{ID: 1})
+			assert.NoError(t, err)
+			assert.Equal(t, `{"id":1}`, string(b))
+
+			var v data
+			assert.NoError(t, tt.json.Unmarshal([]byte(`{"id":2,"name":"a"}`), &v))
+			assert.Equal(t, data{ID: 2, Name: "a"}, v)
+
+			var m map[string]interface{}
+			decoder := tt.json.NewDecoder(strings.NewReader(`{"id":9007199254740993}`))
+			decoder.UseNumber()
+			assert.NoError(t, decoder.Decode(&m))
+			assert.Equal(t, json.Number("9007199254740993"), m["id"])
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	var m map[string]interface{}
+	assert.NoError(t, Number.Unmarshal([]byte(`{"id":9007199254740993}`), &m))
+	assert.Equal(t, json.Number("9007199254740993"), m["id"])
+	b, err := Number.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":9007199254740993}`, string(b))
+	for _, data := range []string{`{"id":1} {}`, `{"id":1}}`, `{"id":1}]`} {
+		assert.Error(t, Number.Unmarshal([]byte(data), &m), data)
+	}
+	assert.NoError(t, Number.Unmarshal([]byte("{\"id\":1} \n"), &m))
+}
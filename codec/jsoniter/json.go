@@ -0,0 +1,43 @@
+// Package jsoniter adapts jsoniter to codec.JSON, import it only where the dependency is allowed.
+package jsoniter
+
+import (
+	"io"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/crochee/lirity/codec"
+)
+
+// Default is jsoniter compatible with encoding/json.
+var Default = New(jsoniter.ConfigCompatibleWithStandardLibrary)
+
+// Number is Default which decodes the numbers in interface{} as json.Number instead of float64,
+// so that the int64 ids keep their precision.
+var Number = New(jsoniter.Config{
+	EscapeHTML:             true,
+	SortMapKeys:            true,
+	ValidateJsonRawMessage: true,
+	UseNumber:              true,
+}.Froze())
+
+// New adapts a jsoniter.API to codec.JSON.
+func New(api jsoniter.API) codec.JSON {
+	return jsoniterJSON{api: api}
+}
+
+type jsoniterJSON struct {
+	api jsoniter.API
+}
+
+func (j jsoniterJSON) Marshal(v interface{}) ([]byte, error) {
+	return j.api.Marshal(v)
+}
+
+func (j jsoniterJSON) Unmarshal(data []byte, v interface{}) error {
+	return j.api.Unmarshal(data, v)
+}
+
+func (j jsoniterJSON) NewDecoder(r io.Reader) codec.Decoder {
+	return j.api.NewDecoder(r)
+}
@@ -0,0 +1,38 @@
+package jsoniter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSON(t *testing.T) {
+	type data struct {
+		ID   uint64 `json:"id"`
+		Name string `json:"name,omitempty"`
+	}
+	b, err := Default.Marshal(data{ID: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(b))
+
+	var v data
+	assert.NoError(t, Default.Unmarshal([]byte(`{"id":2,"name":"a"}`), &v))
+	assert.Equal(t, data{ID: 2, Name: "a"}, v)
+
+	var m map[string]interface{}
+	decoder := Default.NewDecoder(strings.NewReader(`{"id":9007199254740993}`))
+	decoder.UseNumber()
+	assert.NoError(t, decoder.Decode(&m))
+	assert.Equal(t, json.Number("9007199254740993"), m["id"])
+}
+
+func TestNumber(t *testing.T) {
+	var m map[string]interface{}
+	assert.NoError(t, Number.Unmarshal([]byte(`{"id":9007199254740993}`), &m))
+	assert.Equal(t, json.Number("9007199254740993"), m["id"])
+	b, err := Number.Marshal(m)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":9007199254740993}`, string(b))
+}
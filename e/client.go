@@ -0,0 +1,55 @@
+package e
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// DoAndDecode sends req by client, http.DefaultClient if nil, and decodes the 2xx response body into out,
+// an empty body or a nil out is not decoded.
+// A non-2xx response is returned as the ErrorCode in its body, or if the body is empty or not an ErrorCode,
+// as MustPack(status code, 0) with the status text, and the body as the result.
+func DoAndDecode(client *http.Client, req *http.Request, out interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ErrParseContent.WithResult(err)
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fromBody(resp.StatusCode, data)
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	decoder := jsonCodec.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(out); err != nil {
+		return ErrParseContent.WithResult(err)
+	}
+	return nil
+}
+
+// fromBody is From for the body already read, which falls back to the status code
+func fromBody(statusCode int, data []byte) ErrorCode {
+	var result ErrCode
+	if err := result.UnmarshalJSON(data); err == nil && validateErrorCode(&result, codeDigits) == nil {
+		return result.WithStatusCode(statusCode)
+	}
+	ec := &ErrCode{
+		code:   MustPack(statusCode, 0),
+		msg:    http.StatusText(statusCode),
+		remote: true,
+	}
+	if body := bytes.TrimSpace(data); len(body) > 0 {
+		ec.result = string(body)
+	}
+	return ec
+}
@@ -1,14 +1,28 @@
 package e
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"reflect"
 	"strconv"
 
-	"github.com/json-iterator/go"
+	"go.uber.org/multierr"
+
+	"github.com/crochee/lirity/codec"
 )
 
+var jsonCodec = codec.Default
+
+// SetJSON replaces the JSON codec of the package, encoding/json by default, e.g. by jsoniter.Default of codec/jsoniter.
+// It should be called once at startup.
+func SetJSON(j codec.JSON) {
+	jsonCodec = j
+}
+
 type ErrorCode interface {
 	error
 	json.Marshaler
@@ -17,10 +31,23 @@ type ErrorCode interface {
 	Code() string
 	Message() string
 	Result() interface{}
+	// The With methods copy the ErrorCode but share its result, Clone copies the result too
 	WithStatusCode(int) ErrorCode
 	WithCode(string) ErrorCode
 	WithMessage(string) ErrorCode
+	// WithResult keeps the result as is, a value reused after the call, like the pooled async params,
+	// changes the error with it, see WithResultCopy
 	WithResult(interface{}) ErrorCode
+	// WithResultCopy is WithResult with a standalone copy of result, made by a JSON round trip
+	WithResultCopy(interface{}) ErrorCode
+	// WithMessagef is WithMessage with fmt.Sprintf(format, args...)
+	WithMessagef(format string, args ...interface{}) ErrorCode
+	// WithResultf is WithResult with fmt.Sprintf(format, args...)
+	WithResultf(format string, args ...interface{}) ErrorCode
+	// Remote reports whether the error is decoded from a response rather than created locally
+	Remote() bool
+	// Clone returns a copy with a deep copy of the result, so that changing one doesn't change the other
+	Clone() ErrorCode
 }
 
 type InnerError struct {
@@ -30,7 +57,7 @@ type InnerError struct {
 }
 
 func From(response *http.Response) ErrorCode {
-	decoder := jsoniter.ConfigCompatibleWithStandardLibrary.NewDecoder(response.Body)
+	decoder := jsonCodec.NewDecoder(response.Body)
 	decoder.UseNumber()
 	var result ErrCode
 	if err := decoder.Decode(&result); err != nil {
@@ -39,6 +66,16 @@ func From(response *http.Response) ErrorCode {
 	return result.WithStatusCode(response.StatusCode)
 }
 
+// As finds the first ErrorCode in the chain of err, wrapped by fmt.Errorf("%w") or errors.Wrap at any level.
+// *ErrCode can also be the target of errors.As directly.
+func As(err error) (ErrorCode, bool) {
+	var ec ErrorCode
+	if errors.As(err, &ec) {
+		return ec, true
+	}
+	return nil, false
+}
+
 func Froze(code, message string) ErrorCode {
 	return &ErrCode{
 		code: code,
@@ -51,10 +88,44 @@ type ErrCode struct {
 	code   string
 	msg    string
 	result interface{}
+	remote bool
 }
 
 func (e *ErrCode) Error() string {
-	return fmt.Sprintf("code:%s,message:%s,result:%s", e.Code(), e.Message(), e.Result())
+	return fmt.Sprintf("code:%s,message:%s,result:%s", e.Code(), e.Message(), e.resultString())
+}
+
+// LogValue implements slog.LogValuer, so that the error is logged as a group of fields.
+func (e *ErrCode) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("code", e.code),
+		slog.String("message", e.msg),
+	}
+	switch v := e.result.(type) {
+	case nil:
+	case error:
+		attrs = append(attrs, slog.String("result", v.Error()))
+	default:
+		attrs = append(attrs, slog.Any("result", v))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// resultString renders the result as JSON like MarshalJSON, except that errors and strings are kept as is
+func (e *ErrCode) resultString() string {
+	switch v := e.result.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case error:
+		return v.Error()
+	}
+	data, err := jsonCodec.Marshal(e.result)
+	if err != nil {
+		return fmt.Sprint(e.result)
+	}
+	return string(data)
 }
 
 func (e *ErrCode) MarshalJSON() ([]byte, error) {
@@ -63,17 +134,22 @@ func (e *ErrCode) MarshalJSON() ([]byte, error) {
 		Message: e.msg,
 		Result:  e.result,
 	}
-	return jsoniter.ConfigCompatibleWithStandardLibrary.Marshal(inner)
+	return jsonCodec.Marshal(inner)
 }
 
-func (e *ErrCode) UnmarshalJSON(bytes []byte) error {
+// UnmarshalJSON decodes the numbers in result as json.Number,
+// so that large integers keep their precision when marshaled again.
+func (e *ErrCode) UnmarshalJSON(data []byte) error {
+	decoder := jsonCodec.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
 	var result InnerError
-	if err := jsoniter.ConfigCompatibleWithStandardLibrary.Unmarshal(bytes, &result); err != nil {
+	if err := decoder.Decode(&result); err != nil {
 		return err
 	}
 	e.code = result.Code
 	e.msg = result.Message
 	e.result = result.Result
+	e.remote = true
 	return nil
 }
 
@@ -94,6 +170,31 @@ func (e *ErrCode) Result() interface{} {
 	return e.result
 }
 
+// Remote is true if the ErrCode is decoded by From or UnmarshalJSON, it is kept by the With methods.
+func (e *ErrCode) Remote() bool {
+	return e.remote
+}
+
+// ResultAs returns the result of err as T, the result decoded by From,
+// like a map[string]interface{}, is re-decoded into T.
+func ResultAs[T any](err ErrorCode) (T, bool) {
+	var t T
+	if err == nil || err.Result() == nil {
+		return t, false
+	}
+	if v, ok := err.Result().(T); ok {
+		return v, true
+	}
+	data, e := jsonCodec.Marshal(err.Result())
+	if e != nil {
+		return t, false
+	}
+	if e = jsonCodec.Unmarshal(data, &t); e != nil {
+		return t, false
+	}
+	return t, true
+}
+
 func (e *ErrCode) WithStatusCode(statusCode int) ErrorCode {
 	ec := *e
 	ec.code = strconv.Itoa(statusCode) + ec.Code()[3:]
@@ -118,59 +219,189 @@ func (e *ErrCode) WithResult(result interface{}) ErrorCode {
 	return &ec
 }
 
+func (e *ErrCode) WithResultCopy(result interface{}) ErrorCode {
+	return e.WithResult(copyResult(result))
+}
+
+// copyResult copies result into the values of the JSON codec, the numbers are kept as json.Number.
+// It falls back to fmt.Sprint if result can't be marshaled.
+func copyResult(result interface{}) interface{} {
+	switch v := result.(type) {
+	case nil, string:
+		return v
+	case error:
+		return v.Error()
+	}
+	data, err := jsonCodec.Marshal(result)
+	if err != nil {
+		return fmt.Sprint(result)
+	}
+	decoder := jsonCodec.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err = decoder.Decode(&v); err != nil {
+		return fmt.Sprint(result)
+	}
+	return v
+}
+
+// Clone keeps the types of the result unlike WithResultCopy, the unexported struct fields
+// and the errors are shared as they can't be copied.
+func (e *ErrCode) Clone() ErrorCode {
+	ec := *e
+	if e.result != nil {
+		ec.result = deepCopy(reflect.ValueOf(e.result)).Interface()
+	}
+	return &ec
+}
+
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(deepCopy(v.Elem()))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		// error一般不可变，无法深拷贝
+		if v.Type().Implements(errorType) {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func (e *ErrCode) WithMessagef(format string, args ...interface{}) ErrorCode {
+	return e.WithMessage(fmt.Sprintf(format, args...))
+}
+
+func (e *ErrCode) WithResultf(format string, args ...interface{}) ErrorCode {
+	return e.WithResult(fmt.Sprintf(format, args...))
+}
+
 var (
-	// 00~99为服务级别错误码
+	// 00~99为服务级别错误码，即服务000的0000~0099保留给本包，
+	// 各服务使用自己的3位服务号，避免不同团队的错误码冲突
 
 	ErrInternalServerError = Froze("5000000000", "服务器内部错误")
 	ErrInvalidParam        = Froze("4000000001", "请求参数不正确")
 	ErrNotFound            = Froze("4040000002", "资源不存在")
 	ErrNotAllowMethod      = Froze("4050000003", "不允许此方法")
 	ErrParseContent        = Froze("5000000004", "解析内容失败")
+	ErrConflict            = Froze("4090000005", "资源冲突")
+	ErrUnprocessable       = Froze("4220000006", "请求无法处理")
+	ErrTooManyRequests     = Froze("4290000007", "请求过于频繁")
+	ErrServiceUnavailable  = Froze("5030000008", "服务不可用")
+	ErrUnauthorized        = Froze("4010000009", "未认证")
+	ErrForbidden           = Froze("4030000010", "没有权限")
 )
 
-// AddCode business code to codeMessageBox
+// AddCode business code to codeMessageBox, it is AddCodes for a set of codes
 func AddCode(m map[ErrorCode]struct{}) error {
+	codes := make([]ErrorCode, 0, len(m))
+	for errorCode := range m {
+		codes = append(codes, errorCode)
+	}
+	return AddCodes(codes...)
+}
+
+// AddCodes checks codes against the builtin ones and each other,
+// and returns all the invalid and duplicate codes combined by multierr.
+// The error codes 00~99 of the service 000 are reserved for the builtin ones.
+func AddCodes(codes ...ErrorCode) error {
 	temp := make(map[string]string)
-	for errorCode := range map[ErrorCode]struct{}{
-		ErrInternalServerError: {},
-		ErrInvalidParam:        {},
-		ErrNotFound:            {},
-		ErrNotAllowMethod:      {},
-		ErrParseContent:        {},
-	} {
-		if err := validateErrorCode(errorCode); err != nil {
-			return err
+	var errs error
+	builtins := builtinCodes()
+	for i, errorCode := range append(builtins, codes...) {
+		// 内置错误码的位数不随SetCodeDigits变化
+		digits := codeDigits
+		if i < len(builtins) {
+			digits = DefaultCodeDigits
 		}
-		code := errorCode.Code()
-		value, ok := temp[code]
-		if ok {
-			return fmt.Errorf("error code %s(%s) already exists", code, value)
-		}
-		temp[code] = errorCode.Message()
-	}
-	for errorCode := range m {
-		if err := validateErrorCode(errorCode); err != nil {
-			return err
+		if err := validateErrorCode(errorCode, digits); err != nil {
+			errs = multierr.Append(errs, err)
+			continue
 		}
 		code := errorCode.Code()
-		value, ok := temp[code]
-		if ok {
-			return fmt.Errorf("error code %s(%s) already exists", code, value)
+		if value, ok := temp[code]; ok {
+			errs = multierr.Append(errs, fmt.Errorf("error code %s(%s) already exists", code, value))
+			continue
 		}
 		temp[code] = errorCode.Message()
 	}
-	return nil
+	return errs
+}
+
+func builtinCodes() []ErrorCode {
+	return []ErrorCode{
+		ErrInternalServerError,
+		ErrInvalidParam,
+		ErrNotFound,
+		ErrNotAllowMethod,
+		ErrParseContent,
+		ErrConflict,
+		ErrUnprocessable,
+		ErrTooManyRequests,
+		ErrServiceUnavailable,
+		ErrUnauthorized,
+		ErrForbidden,
+	}
 }
 
-// validateErrorCode check err must be 3(http)+3(service)+4(error)
-func validateErrorCode(err ErrorCode) error {
+// validateErrorCode check err must be 3(http)+digits, 3(service)+4(error) by default
+func validateErrorCode(err ErrorCode, digits int) error {
 	code := err.Code()
+	// 先检查长度，StatusCode依赖前3位
+	if l := len(code); l != 3+digits {
+		return fmt.Errorf("error code %s is %d,but it must be %d", code, l, 3+digits)
+	}
 	statusCode := err.StatusCode()
 	if statusCode < 100 || statusCode >= 600 {
 		return fmt.Errorf("error code %s has invalid status code %d", code, statusCode)
 	}
-	if l := len(code); l != 10 {
-		return fmt.Errorf("error code %s is %d,but it must be 10", code, l)
-	}
 	return nil
 }
@@ -0,0 +1,481 @@
+package e
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"github.com/crochee/lirity/codec"
+	"github.com/crochee/lirity/codec/jsoniter"
+)
+
+func TestErrCodeError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result interface{}
+		want   string
+	}{
+		{name: "nil", want: "code:4000000001,message:请求参数不正确,result:"},
+		{name: "string", result: "name", want: "code:4000000001,message:请求参数不正确,result:name"},
+		{name: "error", result: errors.New("eof"), want: "code:4000000001,message:请求参数不正确,result:eof"},
+		{
+			name:   "struct",
+			result: map[string]interface{}{"field": []int{1}},
+			want:   `code:4000000001,message:请求参数不正确,result:{"field":[1]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ErrInvalidParam.WithResult(tt.result).Error())
+		})
+	}
+}
+
+func TestErrCodeLogValue(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	l.Info("failed", "error", ErrNotFound.WithResult(struct {
+		ID int `json:"id"`
+	}{ID: 1}))
+	assert.JSONEq(t, `{"level":"INFO","msg":"failed",`+
+		`"error":{"code":"4040000002","message":"资源不存在","result":{"id":1}}}`, buf.String())
+}
+
+func TestResultAs(t *testing.T) {
+	type detail struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	body := `{"code":"4000000001","message":"请求参数不正确","result":{"id":1,"name":"a"}}`
+	wire := From(&http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(body))})
+
+	v, ok := ResultAs[detail](ErrInvalidParam.WithResult(detail{ID: 1, Name: "a"}))
+	assert.True(t, ok)
+	assert.Equal(t, detail{ID: 1, Name: "a"}, v)
+
+	v, ok = ResultAs[detail](wire)
+	assert.True(t, ok)
+	assert.Equal(t, detail{ID: 1, Name: "a"}, v)
+
+	_, ok = ResultAs[[]string](wire)
+	assert.False(t, ok)
+	_, ok = ResultAs[detail](ErrInvalidParam)
+	assert.False(t, ok)
+	_, ok = ResultAs[detail](nil)
+	assert.False(t, ok)
+}
+
+func TestFromRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "large integer", body: `{"code":"4000000001","message":"请求参数不正确","result":{"id":9007199254740993}}`},
+		{name: "integer result", body: `{"code":"4000000001","message":"请求参数不正确","result":12345678901234567890}`},
+		{name: "float", body: `{"code":"4000000001","message":"请求参数不正确","result":[1.5,-2]}`},
+		{name: "null", body: `{"code":"4000000001","message":"请求参数不正确","result":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := From(&http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(tt.body))})
+			data, e := err.MarshalJSON()
+			assert.NoError(t, e)
+			assert.Equal(t, tt.body, string(data))
+			assert.Equal(t, http.StatusBadRequest, err.StatusCode())
+		})
+	}
+}
+
+func TestRemote(t *testing.T) {
+	body := `{"code":"4040000002","message":"资源不存在","result":null}`
+	remote := From(&http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(body))})
+	assert.True(t, remote.Remote())
+	assert.True(t, remote.WithMessage("not found").Remote())
+	assert.False(t, ErrNotFound.Remote())
+	assert.False(t, Froze("4040000003", "x").WithResult(1).Remote())
+	data, err := remote.MarshalJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(data))
+}
+
+func TestAs(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{name: "nil", err: nil},
+		{name: "plain", err: errors.New("eof")},
+		{name: "direct", err: ErrNotFound, want: ErrNotFound},
+		{name: "one level", err: fmt.Errorf("loading user: %w", ErrNotFound), want: ErrNotFound},
+		{
+			name: "multi level",
+			err:  fmt.Errorf("handler: %w", fmt.Errorf("service: %w", fmt.Errorf("loading user: %w", ErrNotFound))),
+			want: ErrNotFound,
+		},
+		{
+			name: "pkg errors",
+			err:  fmt.Errorf("handler: %w", pkgerrors.Wrap(ErrInvalidParam.WithResult("name"), "bind")),
+			want: ErrInvalidParam.WithResult("name"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := As(tt.err)
+			assert.Equal(t, tt.want != nil, ok)
+			assert.Equal(t, tt.want, got)
+
+			var ec *ErrCode
+			assert.Equal(t, tt.want != nil, errors.As(tt.err, &ec))
+		})
+	}
+}
+
+func TestSetJSON(t *testing.T) {
+	defer SetJSON(codec.Default)
+	SetJSON(jsoniter.Default)
+	body := `{"code":"4000000001","message":"请求参数不正确","result":{"id":9007199254740993}}`
+	err := From(&http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(strings.NewReader(body))})
+	data, e := err.MarshalJSON()
+	assert.NoError(t, e)
+	assert.Equal(t, body, string(data))
+}
+
+func TestLoadCodes(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		want    []ErrorCode
+		wantErr int
+	}{
+		{
+			name: "yaml",
+			table: `
+- code: "4001000001"
+  message: 名称不正确
+- code: 1000002
+  message: 服务暂停
+  status: 503
+`,
+			want: []ErrorCode{Froze("4001000001", "名称不正确"), Froze("5031000002", "服务暂停")},
+		},
+		{
+			name:  "json",
+			table: `[{"code":"4001000001","message":"名称不正确"}]`,
+			want:  []ErrorCode{Froze("4001000001", "名称不正确")},
+		},
+		{name: "empty", want: []ErrorCode{}},
+		{
+			name: "conflicts",
+			table: `
+- {code: "4001000001", message: a}
+- {code: "4001000001", message: b}
+- {code: "4000000001", message: c}
+- {code: "400", message: d}
+`,
+			want: []ErrorCode{
+				Froze("4001000001", "a"), Froze("4001000001", "b"), Froze("4000000001", "c"), Froze("400", "d"),
+			},
+			wantErr: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codes, err := LoadCodes(strings.NewReader(tt.table))
+			assert.Len(t, multierr.Errors(err), tt.wantErr)
+			assert.Equal(t, tt.want, codes)
+		})
+	}
+	_, err := LoadCodes(strings.NewReader("code: 1"))
+	assert.Error(t, err)
+}
+
+func TestAddCodes(t *testing.T) {
+	assert.NoError(t, AddCodes(Froze("4000100001", "a"), Froze("4000100002", "b")))
+	assert.NoError(t, AddCode(map[ErrorCode]struct{}{Froze("4000100001", "a"): {}}))
+
+	err := AddCodes(
+		Froze("4000100001", "a"),
+		Froze("4000100001", "b"),
+		Froze("4040000002", "c"),
+		Froze("400", "d"),
+		Froze("9000100003", "e"),
+	)
+	errs := multierr.Errors(err)
+	assert.Len(t, errs, 4)
+	assert.EqualError(t, errs[0], "error code 4000100001(a) already exists")
+	assert.EqualError(t, errs[1], "error code 4040000002(资源不存在) already exists")
+	assert.EqualError(t, errs[2], "error code 400 is 3,but it must be 10")
+	assert.EqualError(t, errs[3], "error code 9000100003 has invalid status code 900")
+}
+
+func TestWithResultf(t *testing.T) {
+	err := ErrInvalidParam.WithResultf("field %s is bad", "name")
+	assert.Equal(t, ErrInvalidParam.WithResult("field name is bad"), err)
+	got, e := err.MarshalJSON()
+	assert.NoError(t, e)
+	want, e := ErrInvalidParam.WithResult(fmt.Sprintf("field %s is bad", "name")).MarshalJSON()
+	assert.NoError(t, e)
+	assert.Equal(t, want, got)
+
+	err = ErrInvalidParam.WithMessagef("参数%d不正确", 1)
+	assert.Equal(t, "参数1不正确", err.Message())
+	assert.Equal(t, ErrInvalidParam.Code(), err.Code())
+}
+
+func TestWithResultCopy(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+		Data []int  `json:"data"`
+	}
+	p := &payload{Name: "a", Data: []int{1}}
+	err := ErrInvalidParam.WithResultCopy(p)
+	want, e := ErrInvalidParam.WithResult(p).MarshalJSON()
+	assert.NoError(t, e)
+	// 原值被复用后不影响已有的错误
+	p.Name, p.Data[0] = "b", 2
+	got, e := err.MarshalJSON()
+	assert.NoError(t, e)
+	assert.JSONEq(t, string(want), string(got))
+	assert.Equal(t, map[string]interface{}{"name": "a", "data": []interface{}{json.Number("1")}}, err.Result())
+
+	assert.Equal(t, "bad", ErrInvalidParam.WithResultCopy(errors.New("bad")).Result())
+	assert.Nil(t, ErrInvalidParam.WithResultCopy(nil).Result())
+	_, ok := ErrInvalidParam.WithResultCopy(make(chan int)).Result().(string)
+	assert.True(t, ok)
+}
+
+func TestClone(t *testing.T) {
+	type item struct {
+		Tags []string
+	}
+	type payload struct {
+		Name  string
+		Items []*item
+		Meta  map[string]interface{}
+		Err   error
+	}
+	cause := errors.New("bad")
+	base := ErrInvalidParam.WithResult(&payload{
+		Name:  "a",
+		Items: []*item{{Tags: []string{"x"}}},
+		Meta:  map[string]interface{}{"ids": []int{1}},
+		Err:   cause,
+	})
+	clone := base.Clone()
+	p := clone.Result().(*payload)
+	p.Name = "b"
+	p.Items[0].Tags[0] = "y"
+	p.Meta["ids"].([]int)[0] = 2
+	assert.Equal(t, &payload{
+		Name:  "a",
+		Items: []*item{{Tags: []string{"x"}}},
+		Meta:  map[string]interface{}{"ids": []int{1}},
+		Err:   cause,
+	}, base.Result())
+	assert.Equal(t, cause, p.Err)
+	assert.Equal(t, base.Code(), clone.Code())
+	assert.Nil(t, ErrInvalidParam.Clone().Result())
+}
+
+func TestDoAndDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    interface{}
+		wantErr ErrorCode
+	}{
+		{name: "ok", status: http.StatusOK, body: `{"id":1}`, want: map[string]interface{}{"id": json.Number("1")}},
+		{name: "no content", status: http.StatusNoContent},
+		{
+			name:    "error code",
+			status:  http.StatusBadRequest,
+			body:    `{"code":"4000000001","message":"请求参数不正确","result":"name"}`,
+			wantErr: ErrInvalidParam.WithResult("name"),
+		},
+		{
+			name:    "empty error body",
+			status:  http.StatusBadGateway,
+			wantErr: Froze("5020000000", http.StatusText(http.StatusBadGateway)),
+		},
+		{
+			name:    "not json",
+			status:  http.StatusServiceUnavailable,
+			body:    "upstream connect error\n",
+			wantErr: Froze("5030000000", http.StatusText(http.StatusServiceUnavailable)).WithResult("upstream connect error"),
+		},
+		{
+			name:    "invalid 2xx body",
+			status:  http.StatusOK,
+			body:    `{`,
+			wantErr: ErrParseContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+			req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+			assert.NoError(t, err)
+			var out map[string]interface{}
+			err = DoAndDecode(srv.Client(), req, &out)
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+				if tt.want == nil {
+					assert.Nil(t, out)
+				} else {
+					assert.Equal(t, tt.want, out)
+				}
+				return
+			}
+			ec, ok := As(err)
+			if assert.True(t, ok, err) {
+				assert.Equal(t, tt.wantErr.Code(), ec.Code())
+				assert.Equal(t, tt.wantErr.Message(), ec.Message())
+				if tt.wantErr != ErrParseContent {
+					assert.Equal(t, tt.wantErr.Result(), ec.Result())
+					assert.True(t, ec.Remote())
+				}
+			}
+		})
+	}
+}
+
+func TestCodeDigits(t *testing.T) {
+	assert.Equal(t, ErrNotFound.Code(), MustPack(http.StatusNotFound, 2))
+	statusCode, code, err := Unpack(ErrNotFound.Code())
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, statusCode)
+	assert.Equal(t, uint64(2), code)
+	_, _, err = Unpack("404abc0002")
+	assert.Error(t, err)
+
+	SetCodeDigits(6)
+	defer SetCodeDigits(DefaultCodeDigits)
+	legacy := Froze(MustPack(http.StatusBadRequest, 123456), "legacy")
+	assert.Equal(t, "400123456", legacy.Code())
+	assert.Equal(t, http.StatusBadRequest, legacy.StatusCode())
+	// 内置错误码按自身的10位校验
+	assert.NoError(t, AddCodes(legacy))
+	assert.EqualError(t, AddCodes(Froze("4001000001", "default")), "error code 4001000001 is 10,but it must be 9")
+	_, err = Pack(http.StatusBadRequest, 1234567)
+	assert.EqualError(t, err, "business code 1234567 of status code 400 exceeds 6 digits")
+	assert.Panics(t, func() { MustPack(http.StatusBadRequest, 1234567) })
+}
+
+func TestAddCodesCodeDigits(t *testing.T) {
+	SetCodeDigits(4)
+	defer SetCodeDigits(DefaultCodeDigits)
+	tests := []struct {
+		name    string
+		codes   []ErrorCode
+		wantErr int
+	}{
+		{name: "builtin only"},
+		{name: "packed", codes: []ErrorCode{Froze(MustPack(http.StatusBadRequest, 1), "a"), Froze(MustPack(http.StatusNotFound, 9999), "b")}},
+		{name: "default layout", codes: []ErrorCode{Froze("4001000001", "a")}, wantErr: 1},
+		{name: "duplicate", codes: []ErrorCode{Froze("4000001", "a"), Froze("4000001", "b")}, wantErr: 1},
+		{name: "invalid status code", codes: []ErrorCode{Froze("0000001", "a")}, wantErr: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, multierr.Errors(AddCodes(tt.codes...)), tt.wantErr)
+		})
+	}
+	packed, err := Pack(http.StatusBadRequest, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "4000001", packed)
+	assert.Panics(t, func() { MustPack(http.StatusBadRequest, 10000) })
+}
+
+func TestField(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := zap.New(core)
+	l.Error("failed", Field(ErrNotFound.WithResult(map[string]interface{}{"id": 1})))
+	l.Error("failed", Field(ErrInvalidParam.WithResult(errors.New("name is required"))))
+	l.Error("failed", Field(nil))
+	entries := logs.All()
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, map[string]interface{}{"error": map[string]interface{}{
+			"code":        "4040000002",
+			"message":     "资源不存在",
+			"status_code": http.StatusNotFound,
+			"result":      map[string]interface{}{"id": 1},
+		}}, entries[0].ContextMap())
+		assert.Equal(t, map[string]interface{}{"error": map[string]interface{}{
+			"code":        "4000000001",
+			"message":     "请求参数不正确",
+			"status_code": http.StatusBadRequest,
+			"result":      "name is required",
+		}}, entries[1].ContextMap())
+		assert.Empty(t, entries[2].ContextMap())
+	}
+}
+
+func TestBuiltinCodes(t *testing.T) {
+	assert.NoError(t, AddCodes())
+	tests := []struct {
+		err        ErrorCode
+		statusCode int
+	}{
+		{err: ErrConflict, statusCode: http.StatusConflict},
+		{err: ErrUnprocessable, statusCode: http.StatusUnprocessableEntity},
+		{err: ErrTooManyRequests, statusCode: http.StatusTooManyRequests},
+		{err: ErrServiceUnavailable, statusCode: http.StatusServiceUnavailable},
+		{err: ErrUnauthorized, statusCode: http.StatusUnauthorized},
+		{err: ErrForbidden, statusCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Code(), func(t *testing.T) {
+			assert.Equal(t, tt.statusCode, tt.err.StatusCode())
+			assert.Error(t, AddCodes(Froze(tt.err.Code(), "duplicate")))
+		})
+	}
+}
+
+func TestMustRegister(t *testing.T) {
+	defer func() {
+		registry.Lock()
+		registry.codes = make(map[string]ErrorCode)
+		registry.Unlock()
+	}()
+	errName := Froze("4001000001", "名称不正确")
+	errAge := Froze("4001000002", "年龄不正确")
+	assert.NotPanics(t, func() { MustRegister(errName, errAge) })
+	// 同一个变量重复注册不是冲突
+	assert.NotPanics(t, func() { MustRegister(errName) })
+	assert.Panics(t, func() { MustRegister(Froze("4001000001", "重复")) })
+	assert.Panics(t, func() { MustRegister(Froze("4000000001", "内置")) })
+	assert.Panics(t, func() { MustRegister(Froze("4001000003", "a"), Froze("4001000003", "b")) })
+	assert.Equal(t, []ErrorCode{errName, errAge}, Registered())
+	assert.NoError(t, Verify())
+
+	SetCodeDigits(6)
+	defer SetCodeDigits(DefaultCodeDigits)
+	assert.Len(t, multierr.Errors(Verify()), 2)
+}
@@ -0,0 +1,52 @@
+// Package etest provides the assertions of e.ErrorCode for the tests, so that e doesn't import testing.
+package etest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crochee/lirity/e"
+)
+
+// AssertCode reports a failure to tb unless err, or an error of its chain, is an ErrorCode
+// with the code, status code and message of want, the differences are listed one by one.
+// The result isn't compared, since it varies by call. It returns whether the assertion passes.
+func AssertCode(tb testing.TB, err error, want e.ErrorCode) bool {
+	tb.Helper()
+	if want == nil {
+		if err != nil {
+			tb.Errorf("want no error, got %v", err)
+			return false
+		}
+		return true
+	}
+	if err == nil {
+		tb.Errorf("want error code %s, got no error", want.Code())
+		return false
+	}
+	got, ok := e.As(err)
+	if !ok {
+		tb.Errorf("want error code %s, got %T: %v", want.Code(), err, err)
+		return false
+	}
+	var diff []string
+	if got.Code() != want.Code() {
+		diff = append(diff, row("code", want.Code(), got.Code()))
+	}
+	if got.StatusCode() != want.StatusCode() {
+		diff = append(diff, row("status", want.StatusCode(), got.StatusCode()))
+	}
+	if got.Message() != want.Message() {
+		diff = append(diff, row("message", want.Message(), got.Message()))
+	}
+	if len(diff) == 0 {
+		return true
+	}
+	tb.Errorf("error code mismatch:\n%s", strings.Join(diff, "\n"))
+	return false
+}
+
+func row(field string, want, got interface{}) string {
+	return fmt.Sprintf("\t%s:\n\t\twant: %v\n\t\tgot:  %v", field, want, got)
+}
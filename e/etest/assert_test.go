@@ -0,0 +1,60 @@
+package etest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/e"
+)
+
+// recordTB 记录断言失败的信息
+type recordTB struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recordTB) Helper() {}
+
+func (r *recordTB) Errorf(format string, args ...interface{}) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want e.ErrorCode
+		errs []string
+	}{
+		{name: "equal", err: e.ErrInvalidParam.WithResult("name"), want: e.ErrInvalidParam},
+		{name: "wrapped", err: fmt.Errorf("create,%w", e.ErrNotFound), want: e.ErrNotFound},
+		{name: "both nil"},
+		{name: "unexpected error", err: e.ErrNotFound, errs: []string{"want no error, got " + e.ErrNotFound.Error()}},
+		{name: "no error", want: e.ErrNotFound, errs: []string{"want error code 4040000002, got no error"}},
+		{
+			name: "not error code",
+			err:  errors.New("bad"),
+			want: e.ErrNotFound,
+			errs: []string{"want error code 4040000002, got *errors.errorString: bad"},
+		},
+		{
+			name: "mismatch",
+			err:  e.ErrInvalidParam.WithMessage("name is required"),
+			want: e.ErrNotFound,
+			errs: []string{"error code mismatch:\n" +
+				"\tcode:\n\t\twant: 4040000002\n\t\tgot:  4000000001\n" +
+				"\tstatus:\n\t\twant: 404\n\t\tgot:  400\n" +
+				"\tmessage:\n\t\twant: 资源不存在\n\t\tgot:  name is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &recordTB{TB: t}
+			assert.Equal(t, len(tt.errs) == 0, AssertCode(tb, tt.err, tt.want))
+			assert.Equal(t, tt.errs, tb.errs)
+		})
+	}
+}
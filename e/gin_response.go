@@ -2,7 +2,6 @@ package e
 
 import (
 	"github.com/gin-gonic/gin"
-	"github.com/pkg/errors"
 
 	"github.com/crochee/lirity/logger"
 )
@@ -16,6 +15,10 @@ func Code(c *gin.Context, code ErrorCode) {
 // Error gin Response with error
 func Error(c *gin.Context, err error) {
 	logger.From(c.Request.Context()).Sugar().Errorf("%+v", err)
+	if errorCode, ok := As(err); ok {
+		Code(c, errorCode)
+		return
+	}
 	for err != nil {
 		u, ok := err.(interface {
 			Unwrap() error
@@ -29,10 +32,5 @@ func Error(c *gin.Context, err error) {
 		Code(c, ErrInternalServerError)
 		return
 	}
-	var errorCode *ErrCode
-	if errors.As(err, &errorCode) {
-		Code(c, errorCode)
-		return
-	}
 	Code(c, ErrInternalServerError.WithResult(err))
 }
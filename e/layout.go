@@ -0,0 +1,56 @@
+package e
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// DefaultCodeDigits is the digits of the code after the http status code,
+// 3(service)+4(error) by default which makes the 10 digits of the builtin codes.
+const DefaultCodeDigits = 7
+
+var codeDigits = DefaultCodeDigits
+
+// SetCodeDigits sets the digits of the code after the http status code, e.g. 6 for a legacy 6 digits
+// business code, AddCode and AddCodes validate the codes with it. It should be called once at startup,
+// before the codes are created by Pack since they are padded to it.
+// The builtin codes keep their 10 digits and are still checked against DefaultCodeDigits.
+func SetCodeDigits(n int) {
+	codeDigits = n
+}
+
+// Pack returns the code made of the http status code followed by the business code padded to SetCodeDigits,
+// it fails if code has more digits than SetCodeDigits, since the code would not be unpacked back,
+// e.g. a code read from the config or a legacy system.
+func Pack(statusCode int, code uint64) (string, error) {
+	business := strconv.FormatUint(code, 10)
+	if len(business) > codeDigits {
+		return "", fmt.Errorf("business code %s of status code %d exceeds %d digits", business, statusCode, codeDigits)
+	}
+	return fmt.Sprintf("%03d%0*s", statusCode, codeDigits, business), nil
+}
+
+// MustPack is Pack which panics on error, for the codes known at compile time, e.g. in a var block
+func MustPack(statusCode int, code uint64) string {
+	packed, err := Pack(statusCode, code)
+	if err != nil {
+		panic(err)
+	}
+	return packed
+}
+
+// Unpack splits the code made by Pack into the http status code and the business code
+func Unpack(code string) (int, uint64, error) {
+	if l := len(code); l != 3+codeDigits {
+		return 0, 0, fmt.Errorf("error code %s is %d,but it must be %d", code, l, 3+codeDigits)
+	}
+	statusCode, err := strconv.Atoi(code[:3])
+	if err != nil {
+		return 0, 0, fmt.Errorf("error code %s has invalid status code,%w", code, err)
+	}
+	business, err := strconv.ParseUint(code[3:], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error code %s has invalid business code,%w", code, err)
+	}
+	return statusCode, business, nil
+}
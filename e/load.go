@@ -0,0 +1,40 @@
+package e
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// codeEntry is a row of the table read by LoadCodes
+type codeEntry struct {
+	Code    string `yaml:"code"`
+	Message string `yaml:"message"`
+	Status  int    `yaml:"status"`
+}
+
+// LoadCodes reads a YAML, or JSON, list of error codes, e.g. an embedded codes file,
+// and checks them by AddCodes, the error lists all the invalid and duplicate codes.
+// A code with status excludes the status code, it is prefixed by status:
+//
+//	[{code: "4001000001", message: 名称不正确}, {code: "1000002", message: 服务暂停, status: 503}]
+//
+// The codes are returned even if some are invalid, Froze is still the way for the dynamic codes.
+func LoadCodes(r io.Reader) ([]ErrorCode, error) {
+	var entries []codeEntry
+	if err := yaml.NewDecoder(r).Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("cann't decode error codes,%w", err)
+	}
+	codes := make([]ErrorCode, 0, len(entries))
+	for _, entry := range entries {
+		code := entry.Code
+		if entry.Status != 0 {
+			code = strconv.Itoa(entry.Status) + code
+		}
+		codes = append(codes, Froze(code, entry.Message))
+	}
+	return codes, AddCodes(codes...)
+}
@@ -0,0 +1,66 @@
+package e
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var registry = struct {
+	sync.Mutex
+	codes map[string]ErrorCode
+}{codes: make(map[string]ErrorCode)}
+
+// MustRegister registers the codes of a package, e.g. in its var block or init,
+// so that importing the package registers them. It panics if a code is already taken
+// by a builtin or another registered code, which turns a collision into a failure at startup.
+// The layout of the codes is checked later by Verify, since SetCodeDigits is called after init.
+func MustRegister(codes ...ErrorCode) {
+	if err := register(codes...); err != nil {
+		panic(err)
+	}
+}
+
+func register(codes ...ErrorCode) error {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, builtin := range builtinCodes() {
+		for _, code := range codes {
+			if code.Code() == builtin.Code() {
+				return fmt.Errorf("error code %s(%s) is builtin %s", code.Code(), code.Message(), builtin.Message())
+			}
+		}
+	}
+	added := make(map[string]ErrorCode, len(codes))
+	for _, code := range codes {
+		if exist, ok := registry.codes[code.Code()]; ok && exist != code {
+			return fmt.Errorf("error code %s(%s) already exists as %s", code.Code(), code.Message(), exist.Message())
+		}
+		if exist, ok := added[code.Code()]; ok && exist != code {
+			return fmt.Errorf("error code %s(%s) already exists as %s", code.Code(), code.Message(), exist.Message())
+		}
+		added[code.Code()] = code
+	}
+	for key, code := range added {
+		registry.codes[key] = code
+	}
+	return nil
+}
+
+// Registered returns the codes registered by MustRegister, sorted by code
+func Registered() []ErrorCode {
+	registry.Lock()
+	defer registry.Unlock()
+	codes := make([]ErrorCode, 0, len(registry.codes))
+	for _, code := range registry.codes {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code() < codes[j].Code() })
+	return codes
+}
+
+// Verify checks the registered codes with the builtin ones by AddCodes, it is the self-check at startup
+// after SetCodeDigits, e.g. in main or a test importing every package of the service.
+func Verify() error {
+	return AddCodes(Registered()...)
+}
@@ -0,0 +1,19 @@
+package e
+
+import (
+	"github.com/crochee/lirity/validator"
+)
+
+// FromValidationError converts the error of validator.ValidateStruct, or the raw go-playground
+// validator.ValidationErrors, to ErrInvalidParam with the []validator.FieldError as result,
+// so that async and HTTP return the same body for invalid params. The message of an error about no field
+// is the result, and a nil err returns nil.
+func FromValidationError(err error) ErrorCode {
+	if err == nil {
+		return nil
+	}
+	if fieldErrs := validator.FieldErrors(err); len(fieldErrs) > 0 {
+		return ErrInvalidParam.WithResult(fieldErrs)
+	}
+	return ErrInvalidParam.WithResult(err.Error())
+}
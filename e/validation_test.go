@@ -0,0 +1,48 @@
+package e
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/validator"
+)
+
+func TestFromValidationError(t *testing.T) {
+	type param struct {
+		Name string `json:"name" binding:"required"`
+	}
+	v, err := validator.New(validator.WithTagName("json"))
+	assert.NoError(t, err)
+	raw := validator.NewValidator()
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "translated",
+			err:  v.ValidateStruct(param{}),
+			want: `{"code":"4000000001","message":"请求参数不正确","result":[{"namespace":"param.name","field":"name","tag":"required","message":"name为必填字段"}]}`,
+		},
+		{
+			name: "raw",
+			err:  validator.Var(raw, "", "required"),
+			want: `{"code":"4000000001","message":"请求参数不正确","result":[{"namespace":"","field":"","tag":"required","message":"Key: '' Error:Field validation for '' failed on the 'required' tag"}]}`,
+		},
+		{
+			name: "not about a field",
+			err:  errors.New("bad param"),
+			want: `{"code":"4000000001","message":"请求参数不正确","result":"bad param"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := FromValidationError(tt.err).MarshalJSON()
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+	assert.Nil(t, FromValidationError(nil))
+}
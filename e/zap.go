@@ -0,0 +1,33 @@
+package e
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field returns the zap field "error" logging err as an object of its code, message, status_code and result,
+// zap.Error only logs err.Error(). A nil err is skipped.
+func Field(err ErrorCode) zap.Field {
+	if err == nil {
+		return zap.Skip()
+	}
+	if m, ok := err.(zapcore.ObjectMarshaler); ok {
+		return zap.Object("error", m)
+	}
+	return zap.Object("error", &ErrCode{code: err.Code(), msg: err.Message(), result: err.Result()})
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, like LogValue for slog.
+func (e *ErrCode) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("code", e.code)
+	enc.AddString("message", e.msg)
+	enc.AddInt("status_code", e.StatusCode())
+	switch v := e.result.(type) {
+	case nil:
+	case error:
+		enc.AddString("result", v.Error())
+	default:
+		return enc.AddReflected("result", v)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+const base62 = uint64(len(base62Alphabet))
+
+// ErrBase62Overflow is returned when a base62 string is out of the uint64 range
+var ErrBase62Overflow = errors.New("base62 value out of range")
+
+// NextIDBase62 generate id encoded by base62, it's about 11 characters
+func NextIDBase62() (string, error) {
+	id, err := NextID()
+	if err != nil {
+		return "", err
+	}
+	return FormatBase62(id), nil
+}
+
+// FormatBase62 encode id by base62
+func FormatBase62(id uint64) string {
+	if id == 0 {
+		return base62Alphabet[:1]
+	}
+	var buf [11]byte // 62^11 > 2^64
+	i := len(buf)
+	for id > 0 {
+		i--
+		buf[i] = base62Alphabet[id%base62]
+		id /= base62
+	}
+	return string(buf[i:])
+}
+
+// ParseBase62 decode the string encoded by FormatBase62
+func ParseBase62(s string) (uint64, error) {
+	if s == "" {
+		return 0, errors.New("empty base62 string")
+	}
+	var id uint64
+	for i := 0; i < len(s); i++ {
+		v, err := base62Value(s[i])
+		if err != nil {
+			return 0, err
+		}
+		if id > (math.MaxUint64-v)/base62 {
+			return 0, fmt.Errorf("%w,%s", ErrBase62Overflow, s)
+		}
+		id = id*base62 + v
+	}
+	return id, nil
+}
+
+func base62Value(c byte) (uint64, error) {
+	switch {
+	case '0' <= c && c <= '9':
+		return uint64(c - '0'), nil
+	case 'A' <= c && c <= 'Z':
+		return uint64(c-'A') + 10, nil
+	case 'a' <= c && c <= 'z':
+		return uint64(c-'a') + 36, nil
+	default:
+		return 0, fmt.Errorf("invalid base62 character %q", c)
+	}
+}
@@ -0,0 +1,55 @@
+package id
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBase62(t *testing.T) {
+	testList := []struct {
+		name     string
+		input    uint64
+		expected string
+	}{
+		{
+			name:     "zero",
+			input:    0,
+			expected: "0",
+		},
+		{
+			name:     "digit",
+			input:    61,
+			expected: "z",
+		},
+		{
+			name:     "carry",
+			input:    62,
+			expected: "10",
+		},
+		{
+			name:     "max",
+			input:    math.MaxUint64,
+			expected: "LygHa16AHYF",
+		},
+	}
+	for _, data := range testList {
+		t.Run(data.name, func(t *testing.T) {
+			s := FormatBase62(data.input)
+			assert.Equal(t, data.expected, s)
+			id, err := ParseBase62(s)
+			assert.NoError(t, err)
+			assert.Equal(t, data.input, id)
+		})
+	}
+}
+
+func TestParseBase62(t *testing.T) {
+	_, err := ParseBase62("")
+	assert.Error(t, err)
+	_, err = ParseBase62("a-b")
+	assert.Error(t, err)
+	_, err = ParseBase62("LygHa16AHYG")
+	assert.ErrorIs(t, err, ErrBase62Overflow)
+}
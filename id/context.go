@@ -0,0 +1,30 @@
+package id
+
+import "context"
+
+// generate is the NextID called by NextIDContext, replaced in the tests to block
+var generate = NextID
+
+// NextIDContext is NextID returning ctx.Err() if ctx is done before the id is generated,
+// e.g. while sonyflake waits for the next 10ms window. The id generated after that is dropped.
+func NextIDContext(ctx context.Context) (uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	type result struct {
+		id  uint64
+		err error
+	}
+	// sonyflake在锁内sleep，无法中断，只能放弃等待
+	done, f := make(chan result, 1), generate
+	go func() {
+		id, err := f()
+		done <- result{id: id, err: err}
+	}()
+	select {
+	case r := <-done:
+		return r.id, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
@@ -0,0 +1,65 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// sonyflakeTimeBits is the bits of the elapsed time in an id
+const sonyflakeTimeBits = 39
+
+// ErrClockBehind is returned by CheckClock when the system clock is behind StartTime,
+// or behind the time of the last generated id, i.e. it was turned back.
+var ErrClockBehind = errors.New("system clock is behind")
+
+// lastID is the last id generated by NextID, to detect the clock turned back
+var lastID uint64
+
+// storeLastID sets lastID to id unless a concurrent NextID stored a later one
+func storeLastID(id uint64) {
+	for {
+		last := atomic.LoadUint64(&lastID)
+		if id <= last || atomic.CompareAndSwapUint64(&lastID, last, id) {
+			return
+		}
+	}
+}
+
+// Lifetime returns how long the ids can be generated since StartTime, about 174 years
+func Lifetime() time.Duration {
+	return (1 << sonyflakeTimeBits) * sonyflakeTimeUnit
+}
+
+// Elapsed returns the time since StartTime, it is negative if the clock is behind StartTime
+func Elapsed() time.Duration {
+	return clock().Sub(startTime)
+}
+
+// Remaining returns how long the ids can still be generated before NextID returns ErrIDExhausted
+func Remaining() time.Duration {
+	return Lifetime() - Elapsed()
+}
+
+// RemainingYears is Remaining in years, to alert well before the id space is exhausted
+func RemainingYears() float64 {
+	return Remaining().Hours() / 24 / 365.25
+}
+
+// CheckClock returns ErrClockBehind if the system clock is behind StartTime or the last generated id.
+// NextID doesn't fail in that case, it waits for the clock to catch up with the ids generated before,
+// but for the counter of WithClock which returns ErrClockBehind.
+func CheckClock() error {
+	now := clock()
+	if now.Before(startTime) {
+		return fmt.Errorf("%w start time %s", ErrClockBehind, startTime)
+	}
+	if last := atomic.LoadUint64(&lastID); last != 0 {
+		// the time of an id is truncated to sonyflakeTimeUnit
+		if t, _, _ := Decompose(last); now.Add(sonyflakeTimeUnit).Before(t) {
+			return fmt.Errorf("%w the last id generated at %s", ErrClockBehind, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package id
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemaining(t *testing.T) {
+	assert.Equal(t, 174, int(Lifetime().Hours()/24/365.25))
+	assert.Greater(t, Elapsed(), time.Duration(0))
+	assert.InDelta(t, Lifetime()-Elapsed(), Remaining(), float64(time.Second))
+	assert.Greater(t, RemainingYears(), 100.0)
+	assert.Less(t, RemainingYears(), 174.0)
+}
+
+func TestCheckClock(t *testing.T) {
+	defer atomic.StoreUint64(&lastID, 0)
+	_, err := NextID()
+	assert.NoError(t, err)
+	assert.NoError(t, CheckClock())
+
+	atomic.StoreUint64(&lastID, compose(Elapsed()+time.Minute, 1, 0))
+	assert.True(t, errors.Is(CheckClock(), ErrClockBehind))
+}
@@ -0,0 +1,82 @@
+// Package idmetrics exports the id generation as prometheus metrics,
+// it is kept out of package id so that id doesn't depend on prometheus.
+package idmetrics
+
+import (
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/crochee/lirity/id"
+)
+
+// Collector is a prometheus.Collector and an id.Observer, that counts the generated ids
+// and the NextID errors by reason, and reports the machine id as a gauge.
+//
+//	c := idmetrics.NewCollector("app")
+//	id.SetObserver(c)
+//	prometheus.MustRegister(c)
+type Collector struct {
+	generated prometheus.Counter
+	errors    *prometheus.CounterVec
+	machineID prometheus.GaugeFunc
+}
+
+// NewCollector returns a Collector whose metrics are prefixed by namespace
+func NewCollector(namespace string) *Collector {
+	return &Collector{
+		generated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "id",
+			Name:      "generated_total",
+			Help:      "The number of ids generated by NextID.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "id",
+			Name:      "errors_total",
+			Help:      "The number of NextID errors by reason.",
+		}, []string{"reason"}),
+		machineID: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "id",
+			Name:      "machine_id",
+			Help:      "The machine id of the generated ids.",
+		}, func() float64 { return float64(id.MachineID()) }),
+	}
+}
+
+// Generated implements id.Observer
+func (c *Collector) Generated() {
+	c.generated.Inc()
+}
+
+// Failed implements id.Observer
+func (c *Collector) Failed(err error) {
+	c.errors.WithLabelValues(reason(err)).Inc()
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.generated.Describe(ch)
+	c.errors.Describe(ch)
+	c.machineID.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.generated.Collect(ch)
+	c.errors.Collect(ch)
+	c.machineID.Collect(ch)
+}
+
+func reason(err error) string {
+	switch {
+	case errors.Is(err, id.ErrIDExhausted):
+		return "exhausted"
+	case errors.Is(err, id.ErrNoGenerator):
+		return "no_generator"
+	default:
+		return "unknown"
+	}
+}
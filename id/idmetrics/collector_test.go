@@ -0,0 +1,35 @@
+package idmetrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/crochee/lirity/id"
+)
+
+func TestCollector(t *testing.T) {
+	assert.NoError(t, id.Init(id.WithMachineID(func() (uint16, error) { return 7, nil })))
+	c := NewCollector("test")
+	id.SetObserver(c)
+	defer id.SetObserver(nil)
+
+	_, err := id.NextIDs(3)
+	assert.NoError(t, err)
+	c.Failed(fmt.Errorf("%w,over the time limit", id.ErrIDExhausted))
+
+	assert.NoError(t, testutil.CollectAndCompare(c, strings.NewReader(`
+# HELP test_id_errors_total The number of NextID errors by reason.
+# TYPE test_id_errors_total counter
+test_id_errors_total{reason="exhausted"} 1
+# HELP test_id_generated_total The number of ids generated by NextID.
+# TYPE test_id_generated_total counter
+test_id_generated_total 3
+# HELP test_id_machine_id The machine id of the generated ids.
+# TYPE test_id_machine_id gauge
+test_id_machine_id 7
+`)))
+}
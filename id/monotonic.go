@@ -0,0 +1,69 @@
+package id
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sony/sonyflake"
+)
+
+// errWindowExhausted is returned by monotonic when the 256 ids of the current 10ms window are used
+var errWindowExhausted = errors.New("the sequence of the window is exhausted")
+
+// generator is implemented by *sonyflake.Sonyflake
+type generator interface {
+	NextID() (uint64, error)
+}
+
+// monotonic generates ids in the layout of sonyflake by the clock, but instead of sleeping when the
+// sequence of a 10ms window runs out or the clock is turned back, it returns an error,
+// so it never generates an id ahead of the clock
+type monotonic struct {
+	mutex     sync.Mutex
+	startTime int64 // in sonyflakeTimeUnit
+	elapsed   int64
+	sequence  uint16
+	machineID uint16
+	now       func() time.Time
+}
+
+func newMonotonic(startTime time.Time, machineID uint16, now func() time.Time) *monotonic {
+	return &monotonic{
+		startTime: toSonyflakeTime(startTime),
+		elapsed:   -1,
+		machineID: machineID,
+		now:       now,
+	}
+}
+
+func toSonyflakeTime(t time.Time) int64 {
+	return t.UTC().UnixNano() / int64(sonyflakeTimeUnit)
+}
+
+func (m *monotonic) NextID() (uint64, error) {
+	const maskSequence = uint16(1<<sonyflake.BitLenSequence - 1)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	current := toSonyflakeTime(m.now()) - m.startTime
+	switch {
+	case m.elapsed < current:
+		m.elapsed = current
+		m.sequence = 0
+	case m.elapsed > current:
+		return 0, fmt.Errorf("%w the last id generated at %s", ErrClockBehind,
+			time.Unix(0, (m.startTime+m.elapsed)*int64(sonyflakeTimeUnit)).UTC())
+	case m.sequence == maskSequence:
+		return 0, errWindowExhausted
+	default:
+		m.sequence++
+	}
+	if m.elapsed >= 1<<sonyflakeTimeBits {
+		return 0, fmt.Errorf("%w,over the time limit", ErrIDExhausted)
+	}
+	return uint64(m.elapsed)<<(sonyflake.BitLenSequence+sonyflake.BitLenMachineID) |
+		uint64(m.sequence)<<sonyflake.BitLenMachineID |
+		uint64(m.machineID), nil
+}
@@ -0,0 +1,16 @@
+package id
+
+// Observer is notified of every NextID call, e.g. to export metrics,
+// its methods must be safe for concurrent use and return quickly.
+type Observer interface {
+	// Generated is called after an id is generated
+	Generated()
+	// Failed is called with the error returned by NextID
+	Failed(err error)
+}
+
+// SetObserver sets the Observer of NextID, nil removes it.
+// Like Init, it should be called once at startup before any id is generated.
+func SetObserver(o Observer) {
+	observer = o
+}
@@ -0,0 +1,62 @@
+package id
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+type option struct {
+	startTime time.Time
+	machineID func() (uint16, error)
+	clock     func() time.Time
+	// counter 由WithClock设置，以计数器代替读取真实时钟的sonyflake
+	counter bool
+}
+
+type Option func(*option)
+
+// WithMachineID sets where the machine id comes from, e.g. an env var, a config value or
+// a coordination service, the lower 16 bits of the first non-loopback IPv4 address by default
+func WithMachineID(f func() (uint16, error)) Option {
+	return func(o *option) {
+		o.machineID = f
+	}
+}
+
+// WithMachineIDFromEnv reads the machine id from the env var key, e.g. a stable pod ordinal,
+// which must be an integer in 0-65535. It falls back to the IPv4 address if key is unset.
+func WithMachineIDFromEnv(key string) Option {
+	return WithMachineID(func() (uint16, error) {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			return machineID()
+		}
+		id, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid machine id %q in env %s,%w", value, key, err)
+		}
+		return uint16(id), nil
+	})
+}
+
+// WithStartTime sets the time since which the elapsed time of the ids is counted, 2020-01-01 UTC by default.
+// A later start time extends the lifespan of the ids, but changing it after ids exist makes
+// Decompose return wrong times for them and may generate duplicate ids.
+func WithStartTime(t time.Time) Option {
+	return func(o *option) {
+		o.startTime = t
+	}
+}
+
+// WithClock sets the clock of the ids and of Elapsed and CheckClock, time.Now by default,
+// e.g. a fixed time in the tests so that Decompose and Elapsed are deterministic.
+// Sonyflake always reads the real clock, so the ids are generated by a mutex guarded counter in the layout of sonyflake,
+// which fails rather than waiting for now to move, e.g. for more than 256 ids at a fixed time.
+func WithClock(now func() time.Time) Option {
+	return func(o *option) {
+		o.clock = now
+		o.counter = true
+	}
+}
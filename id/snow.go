@@ -2,8 +2,10 @@ package id
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/sony/sonyflake"
@@ -11,24 +13,177 @@ import (
 	"github.com/crochee/lirity/variable"
 )
 
-var sf = sonyflake.NewSonyflake(sonyflake.Settings{
-	StartTime: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
-	MachineID: machineID})
+var (
+	startTime = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	sf        = newSonyflake(sonyflake.Settings{
+		StartTime: startTime,
+		MachineID: func() (uint16, error) {
+			id, err := machineID()
+			machine = id
+			return id, err
+		}})
+	// mono replaces sf if the ids are generated by the clock of WithClock
+	mono *monotonic
+	// machine is the machine id of sf
+	machine uint16
+	// observer is notified of the id generation, set by SetObserver
+	observer Observer
+	// clock is the time of the ids generated by mono, and of Elapsed and CheckClock, see WithClock
+	clock = time.Now
+)
+
+// sonyflakeTimeUnit is the unit of the elapsed time in an id
+const sonyflakeTimeUnit = 10 * time.Millisecond
+
+// mustRetry is how many times MustNextID tries before giving up
+const mustRetry = 3
+
+var (
+	// ErrNoGenerator is returned when the generator couldn't be created,
+	// e.g. there is no IPv4 address for the default machine id, call Init to create it again
+	ErrNoGenerator = errors.New("id generator is not created")
+	// ErrIDExhausted is returned when the elapsed time since StartTime overflows the bits of an id.
+	// The sequence running out in a 10ms window never returns it, NextID waits for the next window instead.
+	ErrIDExhausted = errors.New("id space is exhausted")
+)
+
+// Init replaces the id generator, it should be called once at startup before any id is generated
+func Init(opts ...Option) error {
+	o := &option{
+		startTime: startTime,
+		machineID: machineID,
+		clock:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	id, err := o.machineID()
+	if err != nil {
+		return fmt.Errorf("cann't get machine id,%w", err)
+	}
+	g := newSonyflake(sonyflake.Settings{
+		StartTime: o.startTime,
+		MachineID: func() (uint16, error) { return id, nil }})
+	if g == nil {
+		return fmt.Errorf("start time %s is ahead of now", o.startTime)
+	}
+	startTime, sf, machine, mono, clock = o.startTime, g, id, nil, o.clock
+	if o.counter {
+		mono = newMonotonic(o.startTime, id, o.clock)
+	}
+	atomic.StoreUint64(&lastID, 0)
+	return nil
+}
+
+// newSonyflake is sonyflake.NewSonyflake returning a nil generator instead of a nil *sonyflake.Sonyflake
+func newSonyflake(st sonyflake.Settings) generator {
+	g := sonyflake.NewSonyflake(st)
+	if g == nil {
+		return nil
+	}
+	return g
+}
+
+// StartTime return the time since which the elapsed time of the ids is counted
+func StartTime() time.Time {
+	return startTime
+}
 
 // NextID generate id
 func NextID() (uint64, error) {
-	return sf.NextID()
+	id, err := nextID()
+	if observer != nil {
+		if err != nil {
+			observer.Failed(err)
+		} else {
+			observer.Generated()
+		}
+	}
+	return id, err
+}
+
+func nextID() (uint64, error) {
+	if sf == nil {
+		return 0, ErrNoGenerator
+	}
+	var (
+		id  uint64
+		err error
+	)
+	if mono != nil {
+		if id, err = mono.NextID(); err != nil {
+			return 0, err
+		}
+	} else if id, err = sf.NextID(); err != nil {
+		return 0, fmt.Errorf("%w,%v", ErrIDExhausted, err)
+	}
+	storeLastID(id)
+	return id, nil
+}
+
+// MachineID returns the machine id of the ids generated by NextID
+func MachineID() uint16 {
+	return machine
+}
+
+// NextIDs generate n increasing ids, when the sequence of a 10ms window runs out
+// it continues in the next window. It is a convenience loop over NextID rather than a reserved range,
+// so the ids of concurrent calls interleave. On error no id is returned, the ids generated before are dropped.
+func NextIDs(n int) ([]uint64, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	ids := make([]uint64, n)
+	for i := range ids {
+		id, err := NextID()
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// MustNextID is NextID which retries briefly on error and panics if it still fails
+func MustNextID() uint64 {
+	var err error
+	for i := 0; i < mustRetry; i++ {
+		var id uint64
+		if id, err = NextID(); err == nil {
+			return id
+		}
+		time.Sleep(sonyflakeTimeUnit)
+	}
+	panic(err)
 }
 
 // NextIDString generate id
 func NextIDString() (string, error) {
-	id, err := sf.NextID()
+	id, err := NextID()
 	if err != nil {
 		return "", err
 	}
 	return strconv.FormatUint(id, variable.DecimalSystem), nil
 }
 
+// Decompose return the time the id was generated, its machine id and sequence
+func Decompose(id uint64) (time.Time, uint16, uint16) {
+	parts := sonyflake.Decompose(id)
+	return startTime.Add(time.Duration(parts["time"]) * sonyflakeTimeUnit),
+		uint16(parts["machine-id"]),
+		uint16(parts["sequence"])
+}
+
+// DecomposeString is Decompose for the id generated by NextIDString
+func DecomposeString(id string) (time.Time, uint16, uint16, error) {
+	v, err := strconv.ParseUint(id, variable.DecimalSystem, 64)
+	if err != nil {
+		return time.Time{}, 0, 0, err
+	}
+	t, machine, sequence := Decompose(v)
+	return t, machine, sequence, nil
+}
+
 func machineID() (uint16, error) {
 	ip, err := lower16BitIPV4()
 	if err != nil {
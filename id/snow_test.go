@@ -0,0 +1,230 @@
+package id
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sony/sonyflake"
+	"github.com/stretchr/testify/assert"
+)
+
+func compose(elapsed time.Duration, machine, sequence uint16) uint64 {
+	return uint64(elapsed/sonyflakeTimeUnit)<<(sonyflake.BitLenSequence+sonyflake.BitLenMachineID) |
+		uint64(sequence)<<sonyflake.BitLenMachineID |
+		uint64(machine)
+}
+
+func TestDecompose(t *testing.T) {
+	testList := []struct {
+		name     string
+		elapsed  time.Duration
+		machine  uint16
+		sequence uint16
+	}{
+		{
+			name: "zero",
+		},
+		{
+			name:     "max",
+			elapsed:  (1<<sonyflake.BitLenTime - 1) * sonyflakeTimeUnit,
+			machine:  1<<sonyflake.BitLenMachineID - 1,
+			sequence: 1<<sonyflake.BitLenSequence - 1,
+		},
+		{
+			name:     "normal",
+			elapsed:  3 * 24 * time.Hour,
+			machine:  258,
+			sequence: 7,
+		},
+	}
+	for _, data := range testList {
+		t.Run(data.name, func(t *testing.T) {
+			id := compose(data.elapsed, data.machine, data.sequence)
+			at, machine, sequence := Decompose(id)
+			assert.Equal(t, StartTime().Add(data.elapsed), at)
+			assert.Equal(t, data.machine, machine)
+			assert.Equal(t, data.sequence, sequence)
+
+			at, machine, sequence, err := DecomposeString(strconv.FormatUint(id, 10))
+			assert.NoError(t, err)
+			assert.Equal(t, StartTime().Add(data.elapsed), at)
+			assert.Equal(t, data.machine, machine)
+			assert.Equal(t, data.sequence, sequence)
+		})
+	}
+	_, _, _, err := DecomposeString("not a number")
+	assert.Error(t, err)
+}
+
+func TestNextID(t *testing.T) {
+	assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 9, nil })))
+	id, err := NextID()
+	assert.NoError(t, err)
+	_, machine, _ := Decompose(id)
+	assert.Equal(t, uint16(9), machine)
+
+	old := sf
+	sf = nil
+	defer func() { sf = old }()
+	_, err = NextID()
+	assert.ErrorIs(t, err, ErrNoGenerator)
+	assert.Panics(t, func() { MustNextID() })
+}
+
+func TestNextIDs(t *testing.T) {
+	assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 1, nil })))
+	// more than the 256 ids of a 10ms window
+	ids, err := NextIDs(600)
+	assert.NoError(t, err)
+	assert.Len(t, ids, 600)
+	for i := 1; i < len(ids); i++ {
+		assert.Less(t, ids[i-1], ids[i])
+	}
+	ids, err = NextIDs(0)
+	assert.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// 失败时不返回部分id
+	old := sf
+	sf = nil
+	defer func() { sf = old }()
+	ids, err = NextIDs(3)
+	assert.ErrorIs(t, err, ErrNoGenerator)
+	assert.Nil(t, ids)
+}
+
+func TestWithMachineIDFromEnv(t *testing.T) {
+	const key = "LIRITY_TEST_MACHINE_ID"
+	defer func() { assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 1, nil }))) }()
+	fallback, fallbackErr := machineID()
+	testList := []struct {
+		name    string
+		value   string
+		unset   bool
+		machine uint16
+		wantErr bool
+	}{
+		{name: "ordinal", value: "3", machine: 3},
+		{name: "max", value: "65535", machine: 65535},
+		{name: "unset", unset: true, machine: fallback, wantErr: fallbackErr != nil},
+		{name: "empty", value: "", wantErr: true},
+		{name: "out of range", value: "65536", wantErr: true},
+		{name: "negative", value: "-1", wantErr: true},
+		{name: "not a number", value: "pod-1", wantErr: true},
+	}
+	for _, data := range testList {
+		t.Run(data.name, func(t *testing.T) {
+			assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 7, nil })))
+			t.Setenv(key, data.value)
+			if data.unset {
+				assert.NoError(t, os.Unsetenv(key))
+			}
+			err := Init(WithMachineIDFromEnv(key))
+			if data.wantErr {
+				assert.Error(t, err)
+				// 失败时保留原有的生成器
+				assert.Equal(t, uint16(7), MachineID())
+				id, err := NextID()
+				assert.NoError(t, err)
+				_, machine, _ := Decompose(id)
+				assert.Equal(t, uint16(7), machine)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, data.machine, MachineID())
+			id, err := NextID()
+			assert.NoError(t, err)
+			_, machine, _ := Decompose(id)
+			assert.Equal(t, data.machine, machine)
+		})
+	}
+}
+
+func TestMonotonicWindow(t *testing.T) {
+	now := StartTime().Add(time.Hour)
+	m := newMonotonic(StartTime(), 1, func() time.Time { return now })
+	for i := 0; i < 256; i++ {
+		id, err := m.NextID()
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, compose(time.Hour, 1, uint16(i)), id)
+	}
+	_, err := m.NextID()
+	assert.ErrorIs(t, err, errWindowExhausted)
+	now = now.Add(sonyflakeTimeUnit)
+	id, err := m.NextID()
+	assert.NoError(t, err)
+	assert.Equal(t, compose(time.Hour+sonyflakeTimeUnit, 1, 0), id)
+}
+
+func TestStoreLastID(t *testing.T) {
+	defer atomic.StoreUint64(&lastID, 0)
+	atomic.StoreUint64(&lastID, 0)
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(id uint64) {
+			defer wg.Done()
+			storeLastID(id)
+		}(uint64(i))
+	}
+	wg.Wait()
+	// 并发时保留最大的id
+	assert.Equal(t, uint64(100), atomic.LoadUint64(&lastID))
+	storeLastID(1)
+	assert.Equal(t, uint64(100), atomic.LoadUint64(&lastID))
+}
+
+func TestNextIDContext(t *testing.T) {
+	assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 2, nil })))
+	id, err := NextIDContext(context.Background())
+	assert.NoError(t, err)
+	_, machine, _ := Decompose(id)
+	assert.Equal(t, uint16(2), machine)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = NextIDContext(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 模拟等待下一个时间窗口时取消
+	release := make(chan struct{})
+	generate = func() (uint64, error) {
+		<-release
+		return 0, nil
+	}
+	defer func() {
+		close(release)
+		generate = NextID
+	}()
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = NextIDContext(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestWithClock(t *testing.T) {
+	now := StartTime().Add(48 * time.Hour)
+	assert.NoError(t, Init(WithClock(func() time.Time { return now }), WithMachineID(func() (uint16, error) { return 3, nil })))
+	defer func() { assert.NoError(t, Init(WithMachineID(func() (uint16, error) { return 1, nil }))) }()
+	assert.Equal(t, 48*time.Hour, Elapsed())
+
+	ids, err := NextIDs(2)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{compose(48*time.Hour, 3, 0), compose(48*time.Hour, 3, 1)}, ids)
+	at, machine, sequence := Decompose(ids[1])
+	assert.Equal(t, now, at)
+	assert.Equal(t, uint16(3), machine)
+	assert.Equal(t, uint16(1), sequence)
+	assert.NoError(t, CheckClock())
+
+	// 时钟回拨
+	now = now.Add(-time.Minute)
+	assert.ErrorIs(t, CheckClock(), ErrClockBehind)
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Entry is the log entry passed to the hooks
+type Entry struct {
+	Level   zapcore.Level
+	Time    time.Time
+	Message string
+	Fields  []zap.Field
+}
+
+// hookCore calls the hooks synchronously for the entries it is enabled at
+type hookCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	hooks  []func(Entry)
+}
+
+func (h *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *h
+	clone.fields = make([]zapcore.Field, 0, len(h.fields)+len(fields))
+	clone.fields = append(clone.fields, h.fields...)
+	clone.fields = append(clone.fields, fields...)
+	return &clone
+}
+
+func (h *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if h.Enabled(ent.Level) {
+		return ce.AddCore(ent, h)
+	}
+	return ce
+}
+
+func (h *hookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zap.Field, 0, len(h.fields)+len(fields))
+	all = append(all, h.fields...)
+	all = append(all, fields...)
+	entry := Entry{
+		Level:   ent.Level,
+		Time:    ent.Time,
+		Message: ent.Message,
+		Fields:  all,
+	}
+	for _, hook := range h.hooks {
+		hook(entry)
+	}
+	return nil
+}
+
+func (h *hookCore) Sync() error {
+	return nil
+}
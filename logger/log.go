@@ -1,3 +1,6 @@
+// Package logger configures zap, there is no wrapper interface: New returns the *zap.Logger itself
+// with its sinks, encoding and level, and From returns the one carried by a context,
+// so they can be passed as is to the libraries expecting a *zap.Logger.
 package logger
 
 import (
@@ -8,6 +11,8 @@ import (
 
 type logKey struct{}
 
+type fieldsKey struct{}
+
 func From(ctx context.Context) *zap.Logger {
 	l, ok := ctx.Value(logKey{}).(*zap.Logger)
 	if !ok {
@@ -16,6 +21,70 @@ func From(ctx context.Context) *zap.Logger {
 	return l
 }
 
+// Nop returns a logger discarding everything, e.g. With(ctx, Nop()) removes the logging cost in benchmarks.
+// From returns it too when ctx carries no logger.
+func Nop() *zap.Logger {
+	return zap.NewNop()
+}
+
 func With(ctx context.Context, l *zap.Logger) context.Context {
 	return context.WithValue(ctx, logKey{}, l)
 }
+
+// WithField return a copy of ctx whose logger is the one of ctx with the field key, see WithContextFields
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithContextFields(ctx, zap.Any(key, value))
+}
+
+// WithContextFields return a copy of ctx whose logger is the one of ctx with fields,
+// that is With(ctx, From(ctx).With(fields...)). Unlike AddFields they are logged by From(ctx) too.
+// It isn't named WithFields which is the Option of New.
+func WithContextFields(ctx context.Context, fields ...zap.Field) context.Context {
+	return With(ctx, From(ctx).With(fields...))
+}
+
+// AddFields return a copy of ctx which carries fields after the fields already in ctx,
+// they are added to every line logged by DebugCtx, InfoCtx, WarnCtx and ErrorCtx
+// together with the trace_id and span_id of the OpenTelemetry span in ctx
+func AddFields(ctx context.Context, fields ...zap.Field) context.Context {
+	old := Fields(ctx)
+	merged := make([]zap.Field, 0, len(old)+len(fields))
+	merged = append(merged, old...)
+	merged = append(merged, fields...)
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// Fields return the fields carried by ctx
+func Fields(ctx context.Context) []zap.Field {
+	fields, _ := ctx.Value(fieldsKey{}).([]zap.Field)
+	return fields
+}
+
+// DebugCtx logs msg by the logger in ctx with the fields in ctx
+func DebugCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	fromCtx(ctx).Debug(msg, fields...)
+}
+
+// InfoCtx logs msg by the logger in ctx with the fields in ctx
+func InfoCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	fromCtx(ctx).Info(msg, fields...)
+}
+
+// WarnCtx logs msg by the logger in ctx with the fields in ctx
+func WarnCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	fromCtx(ctx).Warn(msg, fields...)
+}
+
+// ErrorCtx logs msg by the logger in ctx with the fields in ctx
+func ErrorCtx(ctx context.Context, msg string, fields ...zap.Field) {
+	fromCtx(ctx).Error(msg, fields...)
+}
+
+// fromCtx skips the *Ctx frame so that the line of its caller is reported
+func fromCtx(ctx context.Context) *zap.Logger {
+	fields, traces := Fields(ctx), traceFields(ctx)
+	all := make([]zap.Field, 0, len(fields)+len(traces))
+	all = append(all, fields...)
+	all = append(all, traces...)
+	return From(ctx).WithOptions(zap.AddCallerSkip(1)).With(all...)
+}
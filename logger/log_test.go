@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWithContextFields(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	ctx := With(context.Background(), zap.New(core))
+	ctx = WithField(ctx, "queue", "task")
+	child := WithContextFields(ctx, zap.Uint64("delivery_tag", 1), zap.Bool("redelivered", false))
+
+	From(child).Info("child")
+	From(ctx).Info("parent")
+	entries := logs.All()
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, map[string]interface{}{"queue": "task", "delivery_tag": uint64(1), "redelivered": false},
+			entries[0].ContextMap())
+		assert.Equal(t, map[string]interface{}{"queue": "task"}, entries[1].ContextMap())
+	}
+
+	// 没有logger时不会panic
+	From(WithField(context.Background(), "queue", "task")).Info("nop")
+}
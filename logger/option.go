@@ -2,35 +2,86 @@ package logger
 
 import (
 	"io"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 type option struct {
-	skip    int
-	level   zapcore.Level
-	encoder func(zapcore.EncoderConfig) zapcore.Encoder
-	writer  io.Writer
-	fields  []zap.Field
+	skip       int
+	callerSkip int
+	level      *zapcore.Level // nil 时不修改atomic的级别
+	atomic     zap.AtomicLevel
+	encoding   string
+	encoder    func(zapcore.EncoderConfig) zapcore.Encoder
+	writer     io.Writer
+	fields     []zap.Field
+	path       string
+	rotation   Rotation
+	sampling   map[zapcore.Level]sampling
+	sinks      []Sink
+	hookLevel  zapcore.Level
+	hooks      []func(Entry)
+	timeLayout string
+	location   *time.Location
+	clock      func() time.Time
 }
 
 type Option func(*option)
 
+// WithSkip sets how many frames above the zap.Logger method are skipped when reporting the caller,
+// 0 reports the line calling Info and so on, 1 reports the caller of a function wrapping them
 func WithSkip(skip int) Option {
 	return func(o *option) {
 		o.skip = skip
 	}
 }
 
+// WithCallerSkip adds skip on top of the one set by WithSkip,
+// so a logging helper can skip its own frames whatever the base skip is
+func WithCallerSkip(skip int) Option {
+	return func(o *option) {
+		o.callerSkip += skip
+	}
+}
+
+// WithLevel sets the level of the logger, INFO by default. With WithSharedLevel it changes the package level,
+// so that the loggers created with WithSharedLevel but without WithLevel keep the level set by SetLevel
 func WithLevel(level string) Option {
 	return func(o *option) {
-		o.level = newLevel(level)
+		l := newLevel(level)
+		o.level = &l
+	}
+}
+
+// WithAtomicLevel makes the logger use level, e.g. to change the level of a group of loggers,
+// each logger has its own level by default
+func WithAtomicLevel(level zap.AtomicLevel) Option {
+	return func(o *option) {
+		o.atomic = level
+	}
+}
+
+// WithSharedLevel makes the logger use the package level, which is changed at runtime by SetLevel and LevelHandler
+func WithSharedLevel() Option {
+	return WithAtomicLevel(_level)
+}
+
+// WithEncoding selects the encoder by JSONEncoding or ConsoleEncoding,
+// the console one colors levels when it writes to a terminal
+func WithEncoding(encoding string) Option {
+	return func(o *option) {
+		if encoder, ok := encoders[encoding]; ok {
+			o.encoding = encoding
+			o.encoder = encoder
+		}
 	}
 }
 
 func WithEncoder(encoder func(zapcore.EncoderConfig) zapcore.Encoder) Option {
 	return func(o *option) {
+		o.encoding = ""
 		o.encoder = encoder
 	}
 }
@@ -46,3 +97,77 @@ func WithFields(fields ...zap.Field) Option {
 		o.fields = fields
 	}
 }
+
+// WithPath writes logs to the file at path and rotates it by WithRotation.
+// Stdout and Stderr log to the console without rotation.
+func WithPath(path string) Option {
+	return func(o *option) {
+		o.path = path
+	}
+}
+
+// WithRotation sets how the file set by WithPath is rotated
+func WithRotation(r Rotation) Option {
+	return func(o *option) {
+		o.rotation = r
+	}
+}
+
+// WithSampling samples the entries of level, in every second the first entries with the same
+// message are logged and then only one of every thereafter entries.
+// Sampling is disabled by default and levels without WithSampling are never dropped.
+func WithSampling(level string, first, thereafter int) Option {
+	return func(o *option) {
+		if o.sampling == nil {
+			o.sampling = make(map[zapcore.Level]sampling)
+		}
+		o.sampling[newLevel(level)] = sampling{first: first, thereafter: thereafter}
+	}
+}
+
+// WithSink adds sinks which are written at the same time, each with its own level,
+// e.g. everything to Stdout and errors to a dedicated file
+func WithSink(sinks ...Sink) Option {
+	return func(o *option) {
+		o.sinks = append(o.sinks, sinks...)
+	}
+}
+
+// WithHook adds hooks which are called in order for every entry at or above the level
+// set by WithHookLevel, e.g. sending errors to Sentry
+func WithHook(hooks ...func(Entry)) Option {
+	return func(o *option) {
+		o.hooks = append(o.hooks, hooks...)
+	}
+}
+
+// WithHookLevel sets the minimum level of the entries passed to the hooks, ERROR by default
+func WithHookLevel(level string) Option {
+	return func(o *option) {
+		o.hookLevel = newLevel(level)
+	}
+}
+
+// WithTimeFormat sets the layout of the log time for both the console and JSON encoders,
+// ISO8601 with milliseconds by default, e.g. time.RFC3339Nano
+func WithTimeFormat(layout string) Option {
+	return func(o *option) {
+		o.timeLayout = layout
+	}
+}
+
+// WithTimeLocation sets the location the log time is converted to, UTC by default,
+// time.Local keeps the local time
+func WithTimeLocation(loc *time.Location) Option {
+	return func(o *option) {
+		o.location = loc
+	}
+}
+
+// WithClock sets the clock of the log time, the real time by default,
+// e.g. a fixed time for golden tests of the log lines
+func WithClock(now func() time.Time) Option {
+	return func(o *option) {
+		o.clock = now
+	}
+}
@@ -0,0 +1,238 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// lines 解析JSON编码的日志行
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &m), line) {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+func messages(t *testing.T, buf *bytes.Buffer) []string {
+	var result []string
+	for _, line := range lines(t, buf) {
+		result = append(result, line["Message"].(string))
+	}
+	return result
+}
+
+func TestWithPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	rotation := Rotation{MaxSizeMB: 1, MaxAgeDays: 2, MaxBackups: 3, Compress: true}
+	tests := []struct {
+		name string
+		opts []Option
+		want Rotation
+	}{
+		{name: "default rotation", want: DefaultRotation()},
+		{name: "with rotation", opts: []Option{WithRotation(rotation)}, want: rotation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, ok := newWriter(path, tt.want).(*lumberjack.Logger)
+			if assert.True(t, ok) {
+				assert.Equal(t, tt.want, Rotation{
+					MaxSizeMB:  w.MaxSize,
+					MaxAgeDays: w.MaxAge,
+					MaxBackups: w.MaxBackups,
+					Compress:   w.Compress,
+				})
+			}
+			l := New(append(tt.opts, WithPath(path), WithEncoding(JSONEncoding))...)
+			l.Info(tt.name)
+			assert.NoError(t, l.Sync())
+			data, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Contains(t, string(data), tt.name)
+		})
+	}
+	assert.Equal(t, os.Stdout, newWriter(Stdout, DefaultRotation()))
+	assert.Equal(t, os.Stderr, newWriter(Stderr, DefaultRotation()))
+}
+
+func TestWithEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		json     bool
+	}{
+		{name: "json", encoding: JSONEncoding, json: true},
+		{name: "console", encoding: ConsoleEncoding},
+		{name: "unknown keeps console", encoding: "xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			New(WithEncoding(tt.encoding), WithWriter(&buf)).Info("msg", zap.String("key", "value"))
+			var m map[string]interface{}
+			err := json.Unmarshal(buf.Bytes(), &m)
+			if tt.json {
+				assert.NoError(t, err)
+				assert.Equal(t, "msg", m["Message"])
+				assert.Equal(t, "value", m["key"])
+				return
+			}
+			assert.Error(t, err)
+			assert.Contains(t, buf.String(), "\tmsg\t")
+		})
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{name: "disabled", want: 10},
+		{name: "sampled level", opts: []Option{WithSampling(INFO, 2, 100)}, want: 2},
+		{name: "other level", opts: []Option{WithSampling(WARN, 2, 100)}, want: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := New(append(tt.opts, WithEncoding(JSONEncoding), WithWriter(&buf))...)
+			for i := 0; i < 10; i++ {
+				l.Info("same")
+			}
+			assert.Len(t, messages(t, &buf), tt.want)
+		})
+	}
+}
+
+func TestWithSink(t *testing.T) {
+	var main, all, errs bytes.Buffer
+	l := New(WithEncoding(JSONEncoding), WithWriter(&main), WithLevel(INFO),
+		WithSink(Sink{Writer: &all}, Sink{Writer: &errs, Level: ERROR}))
+	l.Debug("debug")
+	l.Info("info")
+	l.Error("error")
+	assert.Equal(t, []string{"info", "error"}, messages(t, &main))
+	assert.Equal(t, []string{"info", "error"}, messages(t, &all))
+	assert.Equal(t, []string{"error"}, messages(t, &errs))
+}
+
+// logHelper 模拟封装日志的函数，返回其记录日志的行号
+func logHelper(l *zap.Logger) int {
+	_, _, line, _ := runtime.Caller(0)
+	l.Info("helper")
+	return line + 1
+}
+
+func TestWithCallerSkip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		skip int // 期望的调用层级，0为logHelper，1为测试
+	}{
+		{name: "default"},
+		{name: "caller skip", opts: []Option{WithCallerSkip(1)}, skip: 1},
+		{name: "additive", opts: []Option{WithSkip(1), WithCallerSkip(-1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			helperLine := logHelper(New(append(tt.opts, WithEncoding(JSONEncoding), WithWriter(&buf))...))
+			_, file, line, _ := runtime.Caller(0)
+			want := file + ":" + strconv.Itoa(line-1)
+			if tt.skip == 0 {
+				want = file + ":" + strconv.Itoa(helperLine)
+			}
+			if got := lines(t, &buf); assert.Len(t, got, 1) {
+				assert.Equal(t, want, got[0]["Caller"])
+			}
+		})
+	}
+}
+
+func TestWithHook(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{name: "default level", want: []string{"error"}},
+		{name: "hook level", opts: []Option{WithHookLevel("warn")}, want: []string{"warn", "error"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var fields []zap.Field
+			hook := func(e Entry) {
+				got = append(got, e.Message)
+				fields = e.Fields
+			}
+			var buf bytes.Buffer
+			l := New(append(tt.opts, WithWriter(&buf), WithHook(hook))...).With(zap.String("node", "a"))
+			l.Info("info")
+			l.Warn("warn")
+			l.Error("error", zap.Int("n", 1))
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, []zap.Field{zap.String("node", "a"), zap.Int("n", 1)}, fields)
+		})
+	}
+}
+
+func TestCtxFields(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]interface{}
+	}{
+		{name: "no fields", ctx: context.Background(), want: map[string]interface{}{}},
+		{
+			name: "fields",
+			ctx:  AddFields(AddFields(context.Background(), zap.String("a", "1")), zap.String("b", "2")),
+			want: map[string]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			name: "trace",
+			ctx:  trace.ContextWithSpanContext(context.Background(), sc),
+			want: map[string]interface{}{TraceIDKey: sc.TraceID().String(), SpanIDKey: sc.SpanID().String()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			ctx := With(tt.ctx, New(WithEncoding(JSONEncoding), WithWriter(&buf), WithLevel(DEBUG)))
+			DebugCtx(ctx, "msg")
+			InfoCtx(ctx, "msg")
+			WarnCtx(ctx, "msg")
+			ErrorCtx(ctx, "msg")
+			got := lines(t, &buf)
+			assert.Len(t, got, 4)
+			for _, line := range got {
+				for _, key := range []string{"Message", "Level", "Time", "Caller", "Stacktrace"} {
+					delete(line, key)
+				}
+				assert.Equal(t, tt.want, line)
+			}
+		})
+	}
+}
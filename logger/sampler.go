@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+type sampling struct {
+	first      int
+	thereafter int
+}
+
+// levelSampler samples entries only of the levels which have a sampler,
+// entries of other levels are written by the Core directly
+type levelSampler struct {
+	zapcore.Core
+	sampled map[zapcore.Level]zapcore.Core
+}
+
+func newLevelSampler(core zapcore.Core, cfg map[zapcore.Level]sampling) zapcore.Core {
+	if len(cfg) == 0 {
+		return core
+	}
+	s := &levelSampler{Core: core, sampled: make(map[zapcore.Level]zapcore.Core, len(cfg))}
+	for level, c := range cfg {
+		s.sampled[level] = zapcore.NewSamplerWithOptions(core, time.Second, c.first, c.thereafter)
+	}
+	return s
+}
+
+func (s *levelSampler) With(fields []zapcore.Field) zapcore.Core {
+	clone := &levelSampler{Core: s.Core.With(fields), sampled: make(map[zapcore.Level]zapcore.Core, len(s.sampled))}
+	for level, c := range s.sampled {
+		clone.sampled[level] = c.With(fields)
+	}
+	return clone
+}
+
+func (s *levelSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c, ok := s.sampled[ent.Level]; ok {
+		return c.Check(ent, ce)
+	}
+	return s.Core.Check(ent, ce)
+}
@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewSlogHandler returns a slog.Handler writing the records through l,
+// so that slog can be used as the front-end of the zap configuration, rotation and sinks.
+// Groups are mapped to zap namespaces, and the fields of AddFields and the trace in ctx are kept at the top level.
+func NewSlogHandler(l *zap.Logger) slog.Handler {
+	return &slogHandler{l: l}
+}
+
+type slogHandler struct {
+	l *zap.Logger
+	// grouped 第一个group起的字段，每条记录在ctx字段之后写入，使ctx字段位于顶层
+	grouped []zap.Field
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.l.Core().Enabled(zapLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	ce := h.l.Check(zapLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Time = r.Time
+	}
+	if r.PC != 0 && ce.Caller.Defined {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+	}
+	ctxFields, traces := Fields(ctx), traceFields(ctx)
+	fields := make([]zap.Field, 0, len(ctxFields)+len(traces)+len(h.grouped)+r.NumAttrs())
+	fields = append(fields, ctxFields...)
+	fields = append(fields, traces...)
+	fields = append(fields, h.grouped...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, a)
+		return true
+	})
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zap.Field, 0, len(attrs))
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
+	}
+	if len(h.grouped) == 0 {
+		return &slogHandler{l: h.l.With(fields...)}
+	}
+	return &slogHandler{l: h.l, grouped: appendFields(h.grouped, fields...)}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{l: h.l, grouped: appendFields(h.grouped, zap.Namespace(name))}
+}
+
+// appendFields appends to a copy of fields, so that the handlers derived from the same one don't share it
+func appendFields(fields []zap.Field, more ...zap.Field) []zap.Field {
+	result := make([]zap.Field, 0, len(fields)+len(more))
+	result = append(result, fields...)
+	return append(result, more...)
+}
+
+// zapLevel maps the slog level to the zap level at or below it
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+func appendAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		attrs := a.Value.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		// 空key的group平铺到当前层级
+		if a.Key == "" {
+			for _, attr := range attrs {
+				fields = appendAttr(fields, attr)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, groupMarshaler(attrs)))
+	}
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	return append(fields, attrField(a))
+}
+
+func attrField(a slog.Attr) zap.Field {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return zap.String(a.Key, a.Value.String())
+	case slog.KindInt64:
+		return zap.Int64(a.Key, a.Value.Int64())
+	case slog.KindUint64:
+		return zap.Uint64(a.Key, a.Value.Uint64())
+	case slog.KindFloat64:
+		return zap.Float64(a.Key, a.Value.Float64())
+	case slog.KindBool:
+		return zap.Bool(a.Key, a.Value.Bool())
+	case slog.KindDuration:
+		return zap.Duration(a.Key, a.Value.Duration())
+	case slog.KindTime:
+		return zap.Time(a.Key, a.Value.Time())
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		return zap.NamedError(a.Key, err)
+	}
+	return zap.Any(a.Key, a.Value.Any())
+}
+
+type groupMarshaler []slog.Attr
+
+func (g groupMarshaler) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, f := range appendAttr(nil, slog.Attr{Value: slog.GroupValue(g...)}) {
+		f.AddTo(enc)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlogHandlerAttrs(t *testing.T) {
+	ts := time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		attr slog.Attr
+		want map[string]interface{}
+	}{
+		{name: "string", attr: slog.String("k", "v"), want: map[string]interface{}{"k": "v"}},
+		{name: "int", attr: slog.Int("k", 1), want: map[string]interface{}{"k": int64(1)}},
+		{name: "uint", attr: slog.Uint64("k", 1), want: map[string]interface{}{"k": uint64(1)}},
+		{name: "float", attr: slog.Float64("k", 1.5), want: map[string]interface{}{"k": 1.5}},
+		{name: "bool", attr: slog.Bool("k", true), want: map[string]interface{}{"k": true}},
+		{name: "duration", attr: slog.Duration("k", time.Second), want: map[string]interface{}{"k": time.Second}},
+		{name: "time", attr: slog.Time("k", ts), want: map[string]interface{}{"k": ts}},
+		{name: "error", attr: slog.Any("k", errors.New("eof")), want: map[string]interface{}{"k": "eof"}},
+		{name: "any", attr: slog.Any("k", []int{1}), want: map[string]interface{}{"k": []interface{}{1}}},
+		{
+			name: "group",
+			attr: slog.Group("g", slog.String("a", "b"), slog.Group("h", slog.Int("c", 1))),
+			want: map[string]interface{}{"g": map[string]interface{}{"a": "b", "h": map[string]interface{}{"c": int64(1)}}},
+		},
+		{name: "inline group", attr: slog.Group("", slog.String("a", "b")), want: map[string]interface{}{"a": "b"}},
+		{name: "empty group", attr: slog.Group("g"), want: map[string]interface{}{}},
+		{name: "empty attr", attr: slog.Attr{}, want: map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			slog.New(NewSlogHandler(zap.New(core))).LogAttrs(context.Background(), slog.LevelInfo, "msg", tt.attr)
+			entries := logs.AllUntimed()
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, tt.want, entries[0].ContextMap())
+			}
+		})
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := slog.New(NewSlogHandler(zap.New(core, zap.AddCaller())))
+	ctx := AddFields(context.Background(), zap.String("request_id", "1"))
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: trace.TraceID{1}, SpanID: trace.SpanID{2}})
+	ctx = trace.ContextWithSpanContext(ctx, sc)
+
+	l.DebugContext(ctx, "dropped")
+	g := l.With("a", 1).WithGroup("g")
+	g.With("c", 3).WithGroup("h").WarnContext(ctx, "warn", "b", 2)
+	g.InfoContext(context.Background(), "sibling")
+	l.Log(ctx, slog.LevelError+2, "error")
+
+	entries := logs.AllUntimed()
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+	assert.Equal(t, zapcore.WarnLevel, entries[0].Level)
+	assert.Equal(t, "warn", entries[0].Message)
+	// ctx中的字段和trace位于顶层，不在group内
+	assert.Equal(t, map[string]interface{}{
+		"a":          int64(1),
+		"request_id": "1",
+		TraceIDKey:   sc.TraceID().String(),
+		SpanIDKey:    sc.SpanID().String(),
+		"g": map[string]interface{}{
+			"c": int64(3),
+			"h": map[string]interface{}{"b": int64(2)},
+		},
+	}, entries[0].ContextMap())
+	assert.Contains(t, entries[0].Caller.File, "slog_test.go")
+	assert.Equal(t, map[string]interface{}{"a": int64(1), "g": map[string]interface{}{}}, entries[1].ContextMap())
+	assert.Equal(t, zapcore.ErrorLevel, entries[2].Level)
+}
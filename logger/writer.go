@@ -1,10 +1,11 @@
 package logger
 
 import (
-	"gopkg.in/natefinch/lumberjack.v2"
 	"io"
 	"os"
 	"path/filepath"
+
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 var (
@@ -13,17 +14,70 @@ var (
 	MaxLogDays = 30
 )
 
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// Rotation controls how a file log is rotated
+type Rotation struct {
+	MaxSizeMB  int  // 单个日志文件最大MaxSizeMB*M大小
+	MaxAgeDays int  // 旧日志保留天数
+	MaxBackups int  // 备份数量
+	Compress   bool // 是否压缩备份
+}
+
+// DefaultRotation return the Rotation built from LogSizeM, MaxLogDays and MaxZip
+func DefaultRotation() Rotation {
+	return Rotation{
+		MaxSizeMB:  LogSizeM,
+		MaxAgeDays: MaxLogDays,
+		MaxBackups: MaxZip,
+	}
+}
+
+// Sink is an output written besides the one set by WithWriter or WithPath
+type Sink struct {
+	Writer   io.Writer // if nil, logs are written to Path
+	Path     string    // Stdout, Stderr or a file path
+	Level    string    // minimum level of the sink, empty means the logger level
+	Rotation Rotation  // rotation of the file at Path, zero value means DefaultRotation
+}
+
+func (s Sink) writer() io.Writer {
+	if s.Writer != nil {
+		return s.Writer
+	}
+	r := s.Rotation
+	if r == (Rotation{}) {
+		r = DefaultRotation()
+	}
+	return newWriter(s.Path, r)
+}
+
 // SetWriter return a io.Writer
 func SetWriter(path string) io.Writer {
 	if path == "" {
 		return os.Stdout
 	}
+	return newWriter(path, DefaultRotation())
+}
+
+// newWriter return os.Stdout or os.Stderr for the console paths,
+// otherwise a rotating file writer
+func newWriter(path string, r Rotation) io.Writer {
+	switch path {
+	case Stdout:
+		return os.Stdout
+	case Stderr:
+		return os.Stderr
+	}
 	return &lumberjack.Logger{
 		Filename:   filepath.Clean(path),
-		MaxSize:    LogSizeM,   // 单个日志文件最大MaxSize*M大小
-		MaxAge:     MaxLogDays, // days
-		MaxBackups: MaxZip,     // 备份数量
-		Compress:   false,      // 不压缩
-		LocalTime:  true,       // 备份名采用本地时间
+		MaxSize:    r.MaxSizeMB,
+		MaxAge:     r.MaxAgeDays,
+		MaxBackups: r.MaxBackups,
+		Compress:   r.Compress,
+		LocalTime:  true, // 备份名采用本地时间
 	}
 }
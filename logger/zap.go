@@ -1,8 +1,17 @@
 package logger
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/mattn/go-isatty"
+	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -17,25 +26,121 @@ const (
 	FATAL  = "FATAL"
 )
 
+const (
+	JSONEncoding    = "json"
+	ConsoleEncoding = "console"
+)
+
+var encoders = map[string]func(zapcore.EncoderConfig) zapcore.Encoder{
+	JSONEncoding:    zapcore.NewJSONEncoder,
+	ConsoleEncoding: NewConsoleEncoder,
+}
+
+// _level is shared by loggers created by New with WithSharedLevel
+var _level = zap.NewAtomicLevel()
+
+// SetLevel changes the level of the loggers created with WithSharedLevel at runtime,
+// level is case-insensitive like "debug", the level is kept if it is unknown.
+func SetLevel(level string) error {
+	l, err := parseLevel(level)
+	if err != nil {
+		return err
+	}
+	_level.SetLevel(l)
+	return nil
+}
+
+// LevelHandler return a http.Handler that GETs the package level and PUTs a new one,
+// the body is same as zap.AtomicLevel.ServeHTTP, like {"level":"debug"}
+func LevelHandler() http.Handler {
+	return _level
+}
+
+// New create a zap.Logger with its own level, set by WithLevel, INFO by default,
+// see WithSharedLevel to change it at runtime by SetLevel
 func New(opts ...Option) *zap.Logger {
 	o := &option{
-		level:   zapcore.InfoLevel,
-		encoder: NewConsoleEncoder,
-		writer:  os.Stdout,
+		atomic:     zap.NewAtomicLevel(),
+		encoding:   ConsoleEncoding,
+		encoder:    NewConsoleEncoder,
+		writer:     os.Stdout,
+		rotation:   DefaultRotation(),
+		hookLevel:  zapcore.ErrorLevel,
+		timeLayout: DefaultTimeLayout,
+		location:   time.UTC,
 	}
 	for _, opt := range opts {
 		opt(o)
 	}
-	//zapcore.NewConsoleEncoder()
+	if o.path != "" {
+		o.writer = newWriter(o.path, o.rotation)
+	}
+	if o.level != nil {
+		o.atomic.SetLevel(*o.level)
+	}
 
-	core := zapcore.NewCore(
-		o.encoder(newEncoderConfig()),
-		zap.CombineWriteSyncers(zapcore.AddSync(o.writer)),
-		o.level,
-	).With(o.fields) // 自带node 信息
+	cores := make([]zapcore.Core, 0, len(o.sinks)+1)
+	cores = append(cores, o.newCore(o.writer, o.atomic))
+	for _, sink := range o.sinks {
+		var enabler zapcore.LevelEnabler = o.atomic
+		if sink.Level != "" {
+			enabler = newLevel(sink.Level)
+		}
+		cores = append(cores, o.newCore(sink.writer(), enabler))
+	}
+	if len(o.hooks) > 0 {
+		cores = append(cores, &hookCore{LevelEnabler: o.hookLevel, hooks: o.hooks})
+	}
+	core := zapcore.NewTee(cores...).With(o.fields) // 自带node 信息
+	core = newLevelSampler(core, o.sampling)
 	// 大于error增加堆栈信息
-	return zap.New(core).WithOptions(zap.AddCaller(), zap.AddCallerSkip(o.skip),
-		zap.AddStacktrace(zapcore.DPanicLevel))
+	zapOpts := []zap.Option{zap.AddCaller(), zap.AddCallerSkip(o.skip + o.callerSkip),
+		zap.AddStacktrace(zapcore.DPanicLevel)}
+	if o.clock != nil {
+		zapOpts = append(zapOpts, zap.WithClock(clock(o.clock)))
+	}
+	return zap.New(core).WithOptions(zapOpts...)
+}
+
+// clock is the zapcore.Clock of WithClock, the tickers of zap still use the real time
+type clock func() time.Time
+
+func (c clock) Now() time.Time {
+	return c()
+}
+
+func (c clock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(d)
+}
+
+// Sync flushes l and return the error instead of printing it,
+// errors of syncing a console like "sync /dev/stdout: invalid argument" are ignored.
+// A nil l is a no-op, so that it can be deferred before the logger is created.
+func Sync(l *zap.Logger) error {
+	if l == nil {
+		return nil
+	}
+	var errs error
+	for _, err := range multierr.Errors(l.Sync()) {
+		if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) {
+			continue
+		}
+		errs = multierr.Append(errs, err)
+	}
+	return errs
+}
+
+func (o *option) newCore(w io.Writer, enabler zapcore.LevelEnabler) zapcore.Core {
+	cfg := newEncoderConfig()
+	cfg.EncodeTime = timeEncoder(o.timeLayout, o.location)
+	if o.encoding == ConsoleEncoding && isTerminal(w) {
+		cfg.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	return zapcore.NewCore(
+		o.encoder(cfg),
+		zap.CombineWriteSyncers(zapcore.AddSync(w)),
+		enabler,
+	)
 }
 
 func newEncoderConfig() zapcore.EncoderConfig {
@@ -48,16 +153,36 @@ func newEncoderConfig() zapcore.EncoderConfig {
 		StacktraceKey:  "Stacktrace",
 		LineEnding:     zapcore.DefaultLineEnding,
 		EncodeLevel:    zapcore.CapitalLevelEncoder,
-		EncodeTime:     zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05"),
+		EncodeTime:     timeEncoder(DefaultTimeLayout, time.UTC),
 		EncodeDuration: zapcore.SecondsDurationEncoder,
 		EncodeCaller:   zapcore.FullCallerEncoder,
 		EncodeName:     zapcore.FullNameEncoder,
 	}
 }
 
+// DefaultTimeLayout is ISO8601 with milliseconds
+const DefaultTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+func timeEncoder(layout string, loc *time.Location) zapcore.TimeEncoder {
+	return func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+		if loc != nil {
+			t = t.In(loc)
+		}
+		enc.AppendString(t.Format(layout))
+	}
+}
+
+// newLevel is parseLevel which falls back to INFO
 func newLevel(level string) zapcore.Level {
-	l := zap.InfoLevel
-	if temp, ok := map[string]zapcore.Level{
+	l, err := parseLevel(level)
+	if err != nil {
+		return zap.InfoLevel
+	}
+	return l
+}
+
+func parseLevel(level string) (zapcore.Level, error) {
+	if l, ok := map[string]zapcore.Level{
 		DEBUG:  zap.DebugLevel,
 		INFO:   zap.InfoLevel,
 		WARN:   zap.WarnLevel,
@@ -65,8 +190,16 @@ func newLevel(level string) zapcore.Level {
 		DPanic: zap.DPanicLevel,
 		PANIC:  zap.PanicLevel,
 		FATAL:  zap.FatalLevel,
-	}[level]; ok {
-		l = temp
+	}[strings.ToUpper(strings.TrimSpace(level))]; ok {
+		return l, nil
 	}
-	return l
+	return zap.InfoLevel, fmt.Errorf("unknown log level %q", level)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
 }
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestWithTimeFormat(t *testing.T) {
+	shanghai := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name   string
+		opts   []Option
+		layout string
+		loc    *time.Location
+	}{
+		{name: "default", layout: DefaultTimeLayout, loc: time.UTC},
+		{
+			name:   "rfc3339nano",
+			opts:   []Option{WithTimeFormat(time.RFC3339Nano), WithTimeLocation(shanghai)},
+			layout: time.RFC3339Nano,
+			loc:    shanghai,
+		},
+	}
+	for _, tt := range tests {
+		for _, encoding := range []string{ConsoleEncoding, JSONEncoding} {
+			t.Run(tt.name+"/"+encoding, func(t *testing.T) {
+				var buf bytes.Buffer
+				before := time.Now().Add(-time.Second)
+				l := New(append(tt.opts, WithEncoding(encoding), WithWriter(&buf))...)
+				l.Info("msg")
+				out := buf.String()
+				var found bool
+				for _, field := range strings.FieldsFunc(out, func(r rune) bool {
+					return r == '\t' || r == '"' || r == ' '
+				}) {
+					at, err := time.ParseInLocation(tt.layout, field, tt.loc)
+					if err != nil {
+						continue
+					}
+					found = true
+					_, offset := at.Zone()
+					_, want := time.Now().In(tt.loc).Zone()
+					assert.Equal(t, want, offset)
+					assert.True(t, at.After(before))
+				}
+				assert.True(t, found, out)
+			})
+		}
+	}
+}
+
+func TestWithClock(t *testing.T) {
+	now := time.Date(2021, 2, 3, 4, 5, 6, 7000000, time.UTC)
+	var buf bytes.Buffer
+	l := New(WithEncoding(JSONEncoding), WithWriter(&buf), WithClock(func() time.Time { return now }))
+	l.Info("msg")
+	assert.Contains(t, buf.String(), `"`+now.Format(DefaultTimeLayout)+`"`)
+}
+
+func TestSync(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, Sync(New(WithWriter(&buf))))
+	assert.NoError(t, Sync(Nop()))
+	assert.NotPanics(t, func() { assert.NoError(t, Sync(nil)) })
+}
+
+func TestSetLevel(t *testing.T) {
+	defer _level.SetLevel(_level.Level())
+	var first, second, own bytes.Buffer
+	l := New(WithWriter(&first), WithSharedLevel())
+	other := New(WithWriter(&own))
+	assert.NoError(t, SetLevel("debug"))
+	// 未指定WithLevel的New不覆盖运行时设置的级别
+	New(WithWriter(&second), WithSharedLevel())
+	l.Debug("after set level")
+	assert.Contains(t, first.String(), "after set level")
+	// 未共享级别的logger不受SetLevel影响
+	other.Debug("own level")
+	assert.Empty(t, own.String())
+
+	assert.Error(t, SetLevel("verbose"))
+	assert.Equal(t, zapcore.DebugLevel, _level.Level())
+
+	tests := []struct {
+		name string
+		opts []Option
+		want zapcore.Level
+	}{
+		{name: "keep", opts: []Option{WithSharedLevel()}, want: zapcore.DebugLevel},
+		{name: "with level", opts: []Option{WithSharedLevel(), WithLevel("Warn")}, want: zapcore.WarnLevel},
+		{name: "own level", opts: []Option{WithLevel(ERROR)}, want: zapcore.WarnLevel},
+		{
+			name: "with atomic level",
+			opts: []Option{WithLevel(ERROR), WithAtomicLevel(zap.NewAtomicLevel())},
+			want: zapcore.WarnLevel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			New(append(tt.opts, WithWriter(&second))...)
+			assert.Equal(t, tt.want, _level.Level())
+		})
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	defer _level.SetLevel(_level.Level())
+	var buf bytes.Buffer
+	l := New(WithWriter(&buf), WithSharedLevel(), WithLevel(INFO))
+	w := httptest.NewRecorder()
+	LevelHandler().ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"level":"error"}`)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	l.Warn("dropped")
+	l.Error("kept")
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Contains(t, buf.String(), "kept")
+}
@@ -3,10 +3,30 @@ package routine
 import "context"
 
 type option struct {
-	recoverFunc func(ctx context.Context, r interface{})
+	recoverFunc   func(ctx context.Context, r interface{}, stack []byte)
+	size          int
+	cancelOnError bool
 }
 
 // Recover register to Pool
 func Recover(f func(context.Context, interface{})) func(*option) {
+	return func(o *option) {
+		o.recoverFunc = func(ctx context.Context, r interface{}, _ []byte) { f(ctx, r) }
+	}
+}
+
+// RecoverWithStack register to Pool, f receives the stack of the goroutine where it panicked
+func RecoverWithStack(f func(ctx context.Context, r interface{}, stack []byte)) func(*option) {
 	return func(o *option) { o.recoverFunc = f }
 }
+
+// Size limits how many goroutines of Pool run at the same time, Go blocks when n are running,
+// the ones started by TryGoUnbounded don't count
+func Size(n int) func(*option) {
+	return func(o *option) { o.size = n }
+}
+
+// CancelOnError cancels the context of Pool when a goroutine started by GoE returns an error
+func CancelOnError() func(*option) {
+	return func(o *option) { o.cancelOnError = true }
+}
@@ -2,61 +2,281 @@ package routine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime/debug"
 	"sync"
+	"time"
+
+	"go.uber.org/multierr"
 )
 
+// ErrWaitTimeout is returned by WaitTimeout when goroutines are still running.
+var ErrWaitTimeout = errors.New("wait timeout")
+
 type Pool struct {
-	waitGroup sync.WaitGroup
-	ctx       context.Context
-	cancel    context.CancelFunc
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	drained chan struct{}
+	// mutex 保护running和idle，使启动goroutine与Wait的停止互斥
+	mutex    sync.Mutex
+	running  int
+	idle     chan struct{} // closed once running drops to 0
+	sem      chan struct{}
+	errMutex sync.Mutex
+	errs     error
 	option
 }
 
 // NewPool creates a Pool.
 func NewPool(parentCtx context.Context, opts ...func(*option)) *Pool {
-	ctx, cancel := context.WithCancel(parentCtx)
+	ctx, cancel := context.WithCancelCause(parentCtx)
 	p := &Pool{
-		ctx:    ctx,
-		cancel: cancel,
-		option: option{recoverFunc: defaultRecoverGoroutine},
+		ctx:     ctx,
+		cancel:  cancel,
+		drained: make(chan struct{}),
+		option:  option{recoverFunc: defaultRecoverGoroutine},
 	}
 	for _, opt := range opts {
 		opt(&p.option)
 	}
+	if p.size > 0 {
+		p.sem = make(chan struct{}, p.size)
+	}
 	return p
 }
 
 // Go starts a recoverable goroutine with a context.
+// If the Pool is full, it blocks until a goroutine exits,
+// the goroutine is dropped if the Pool is stopped meanwhile or before, see TryGo and Closed.
+// The context is the one of the Pool, derived from the parent of NewPool,
+// so it carries all the values of the parent, like the logger, and is canceled by Cancel, Stop and Wait.
 func (p *Pool) Go(goroutine func(context.Context)) {
-	p.waitGroup.Add(1)
+	p.goCtx(p.ctx, goroutine)
+}
+
+// TryGo is Go which reports whether the goroutine is started, false if it is dropped since the Pool is stopped.
+func (p *Pool) TryGo(goroutine func(context.Context)) bool {
+	return p.goCtx(p.ctx, goroutine)
+}
+
+// TryGoUnbounded is TryGo without taking a slot of Size, it never blocks.
+// It is for the long-lived goroutines starting the others, like the consume loops,
+// which would otherwise hold the slots the goroutines they start need.
+// The goroutine is still waited by Wait and counted by Running.
+func (p *Pool) TryGoUnbounded(goroutine func(context.Context)) bool {
+	return p.start(p.ctx, goroutine, false)
+}
+
+// Closed reports whether the Pool is stopped, by Cancel, Stop, Wait or its parent context, or drained by Drain,
+// the goroutines started afterwards are dropped.
+func (p *Pool) Closed() bool {
+	if p.ctx.Err() != nil {
+		return true
+	}
+	select {
+	case <-p.drained:
+		return true
+	default:
+		return false
+	}
+}
+
+// Drain stops accepting goroutines and returns at once, unlike Cancel the context isn't canceled,
+// so the running goroutines go on until they finish, which Wait waits for.
+// It is the first phase of an ordered shutdown, e.g. draining the consumer pool before the background pool.
+func (p *Pool) Drain() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	select {
+	case <-p.drained:
+	default:
+		close(p.drained)
+	}
+}
+
+// Drained is closed by Drain, the long-lived goroutines, like the consume loops, return on it,
+// otherwise Wait waits for them forever.
+func (p *Pool) Drained() <-chan struct{} {
+	return p.drained
+}
+
+// GoWith is Go with a context derived from ctx instead of the Pool's,
+// it carries the values of ctx, not the ones of the parent of NewPool,
+// and is canceled when either ctx or the Pool is canceled.
+// It reports whether the goroutine is started, false if it is dropped since the Pool is stopped.
+func (p *Pool) GoWith(ctx context.Context, goroutine func(context.Context)) bool {
+	ctx, cancel := context.WithCancelCause(ctx)
+	// 传递Pool的取消原因
+	stop := context.AfterFunc(p.ctx, func() { cancel(context.Cause(p.ctx)) })
+	started := p.goCtx(ctx, func(ctx context.Context) {
+		defer cancel(nil)
+		defer stop()
+		goroutine(ctx)
+	})
+	if !started {
+		stop()
+		cancel(nil)
+	}
+	return started
+}
+
+func (p *Pool) goCtx(ctx context.Context, goroutine func(context.Context)) bool {
+	return p.start(ctx, goroutine, true)
+}
+
+// start runs goroutine, it takes a slot of Size if bounded
+func (p *Pool) start(ctx context.Context, goroutine func(context.Context), bounded bool) bool {
+	if p.Closed() {
+		return false
+	}
+	release := bounded && p.sem != nil
+	if release {
+		select {
+		case p.sem <- struct{}{}:
+		case <-p.ctx.Done():
+			return false
+		case <-p.drained:
+			return false
+		}
+	}
+	// 在锁内再次检查，Wait在锁内确认没有goroutine后停止Pool，之后不再启动
+	p.mutex.Lock()
+	if p.Closed() {
+		p.mutex.Unlock()
+		if release {
+			<-p.sem
+		}
+		return false
+	}
+	p.running++
+	if p.running == 1 {
+		p.idle = make(chan struct{})
+	}
+	p.mutex.Unlock()
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
 				if p.recoverFunc != nil {
-					p.recoverFunc(p.ctx, r)
+					p.recoverFunc(ctx, r, debug.Stack())
 				}
 			}
-			p.waitGroup.Done()
+			if release {
+				<-p.sem
+			}
+			p.mutex.Lock()
+			p.running--
+			if p.running == 0 {
+				close(p.idle)
+			}
+			p.mutex.Unlock()
 		}()
-		goroutine(p.ctx)
+		goroutine(ctx)
 	}()
+	return true
+}
+
+// GoE is Go for a goroutine returning an error, all the errors are returned by Wait.
+func (p *Pool) GoE(goroutine func(context.Context) error) {
+	p.Go(func(ctx context.Context) {
+		err := goroutine(ctx)
+		if err == nil {
+			return
+		}
+		p.errMutex.Lock()
+		p.errs = multierr.Append(p.errs, err)
+		p.errMutex.Unlock()
+		if p.cancelOnError {
+			p.Cancel(err)
+		}
+	})
+}
+
+// Running returns the number of running goroutines.
+func (p *Pool) Running() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.running
+}
+
+// Cap returns the limit set by Size, 0 means no limit.
+func (p *Pool) Cap() int {
+	return cap(p.sem)
+}
+
+// Wait waits all started routines, waiting for their termination,
+// and returns the errors of the goroutines started by GoE, or the cause given to Cancel if there is none.
+// After Drain, it is the second phase of the shutdown.
+func (p *Pool) Wait() error {
+	p.wait(nil)
+	return p.err()
 }
 
-// Wait waits all started routines, waiting for their termination.
-func (p *Pool) Wait() {
-	p.waitGroup.Wait()
-	p.cancel()
+// WaitTimeout is Wait which gives up after d and returns ErrWaitTimeout,
+// the goroutines are left running and can be waited again.
+func (p *Pool) WaitTimeout(d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	if !p.wait(timer.C) {
+		return ErrWaitTimeout
+	}
+	return p.err()
+}
+
+// wait waits until no goroutine is running or timeout fires, false for the timeout.
+// The Pool is canceled under the mutex once idle, so no goroutine starts after wait returns.
+func (p *Pool) wait(timeout <-chan time.Time) bool {
+	for {
+		p.mutex.Lock()
+		if p.running == 0 {
+			p.Cancel(nil)
+			p.mutex.Unlock()
+			return true
+		}
+		idle := p.idle
+		p.mutex.Unlock()
+		select {
+		case <-idle:
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// Cancel cancels the context of Pool with err as the cause, only the first cancellation counts,
+// it is context.Cause of the Pool context and of the contexts derived from it, like the ones of GoWith.
+func (p *Pool) Cancel(err error) {
+	p.cancel(err)
+}
+
+// Cause returns context.Cause of the Pool context, nil until it is canceled,
+// context.Canceled for a plain cancellation, or the cause of its parent if the parent was canceled first.
+func (p *Pool) Cause() error {
+	return context.Cause(p.ctx)
+}
+
+// err returns the errors of GoE, or the cause of cancellation other than a plain one
+func (p *Pool) err() error {
+	p.errMutex.Lock()
+	errs := p.errs
+	p.errMutex.Unlock()
+	if errs != nil {
+		return errs
+	}
+	// 仅返回Cancel设置的原因，父context结束或Cancel(nil)不视为错误
+	if cause := p.Cause(); cause != p.ctx.Err() {
+		return cause
+	}
+	return nil
 }
 
 // Stop stops all started routines, waiting for their termination.
 func (p *Pool) Stop() {
-	p.cancel()
-	p.waitGroup.Wait()
+	p.Cancel(nil)
+	p.wait(nil)
 }
 
-func defaultRecoverGoroutine(_ context.Context, err interface{}) {
-	_, _ = fmt.Fprintf(os.Stderr, "Error:%v\nStack: %s", err, debug.Stack())
+func defaultRecoverGoroutine(_ context.Context, err interface{}, stack []byte) {
+	_, _ = fmt.Fprintf(os.Stderr, "Error:%v\nStack: %s", err, stack)
 }
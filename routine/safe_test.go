@@ -2,8 +2,14 @@ package routine
 
 import (
 	"context"
+	"errors"
 	"log"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
 )
 
 func TestNewPool(t *testing.T) {
@@ -66,3 +72,279 @@ func TestNewPool(t *testing.T) {
 		})
 	}
 }
+
+func TestPoolSize(t *testing.T) {
+	p := NewPool(context.Background(), Size(2))
+	assert.Equal(t, 2, p.Cap())
+	var (
+		running int32
+		max     int32
+		release = make(chan struct{})
+	)
+	for i := 0; i < 6; i++ {
+		go p.Go(func(ctx context.Context) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				old := atomic.LoadInt32(&max)
+				if n <= old || atomic.CompareAndSwapInt32(&max, old, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 2, p.Running())
+	close(release)
+	time.Sleep(50 * time.Millisecond)
+	p.Wait()
+	assert.Equal(t, 0, p.Running())
+	assert.LessOrEqual(t, atomic.LoadInt32(&max), int32(2))
+	assert.Equal(t, 0, NewPool(context.Background()).Cap())
+}
+
+func TestPoolGoE(t *testing.T) {
+	testList := []struct {
+		name     string
+		opts     []func(*option)
+		canceled bool
+	}{
+		{
+			name: "default",
+		},
+		{
+			name:     "cancel",
+			opts:     []func(*option){CancelOnError()},
+			canceled: true,
+		},
+	}
+	for _, data := range testList {
+		t.Run(data.name, func(t *testing.T) {
+			p := NewPool(context.Background(), data.opts...)
+			done := make(chan struct{})
+			var canceled bool
+			p.GoE(func(ctx context.Context) error {
+				return errors.New("error0")
+			})
+			p.GoE(func(ctx context.Context) error {
+				return errors.New("error1")
+			})
+			p.GoE(func(ctx context.Context) error {
+				select {
+				case <-ctx.Done():
+					canceled = true
+				case <-time.After(100 * time.Millisecond):
+				}
+				close(done)
+				return nil
+			})
+			<-done
+			err := p.Wait()
+			assert.Error(t, err)
+			assert.Len(t, multierr.Errors(err), 2)
+			assert.Equal(t, data.canceled, canceled)
+		})
+	}
+	assert.NoError(t, NewPool(context.Background()).Wait())
+}
+
+func TestPoolWaitTimeout(t *testing.T) {
+	p := NewPool(context.Background())
+	release := make(chan struct{})
+	p.Go(func(ctx context.Context) {
+		<-release
+	})
+	assert.ErrorIs(t, p.WaitTimeout(10*time.Millisecond), ErrWaitTimeout)
+	assert.Equal(t, 1, p.Running())
+	close(release)
+	assert.NoError(t, p.WaitTimeout(time.Second))
+	assert.NoError(t, p.Wait())
+	assert.NoError(t, p.WaitTimeout(time.Second))
+}
+
+func TestRecoverWithStack(t *testing.T) {
+	var (
+		recovered interface{}
+		stack     []byte
+	)
+	p := NewPool(context.Background(), RecoverWithStack(func(ctx context.Context, r interface{}, s []byte) {
+		recovered, stack = r, s
+	}))
+	p.Go(func(ctx context.Context) {
+		panic("op")
+	})
+	assert.NoError(t, p.Wait())
+	assert.Equal(t, "op", recovered)
+	assert.Contains(t, string(stack), "TestRecoverWithStack")
+}
+
+func TestPoolCancel(t *testing.T) {
+	cause := errors.New("shutdown")
+	p := NewPool(context.Background())
+	p.Go(func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	p.Cancel(cause)
+	p.Cancel(errors.New("later"))
+	assert.ErrorIs(t, p.Wait(), cause)
+	assert.ErrorIs(t, p.Cause(), cause)
+
+	p = NewPool(context.Background())
+	assert.NoError(t, p.Cause())
+	p.Stop()
+	p.Cancel(cause)
+	assert.ErrorIs(t, p.Cause(), context.Canceled)
+	assert.NoError(t, p.Wait())
+
+	// 取消原因可由Pool及GoWith的context读取
+	p = NewPool(context.Background())
+	causes := make(chan error, 2)
+	p.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+	})
+	p.GoWith(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		causes <- context.Cause(ctx)
+	})
+	p.Cancel(cause)
+	assert.ErrorIs(t, p.Wait(), cause)
+	assert.Equal(t, []error{cause, cause}, []error{<-causes, <-causes})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p = NewPool(ctx)
+	p.Cancel(cause)
+	assert.NoError(t, p.Wait())
+
+	// 父context超时不视为错误
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	p = NewPool(ctx)
+	p.Go(func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	assert.NoError(t, p.Wait())
+	assert.ErrorIs(t, p.Cause(), context.DeadlineExceeded)
+}
+
+type ctxKey struct{}
+
+func TestPoolGoWith(t *testing.T) {
+	parent := context.WithValue(context.Background(), ctxKey{}, "parent")
+	p := NewPool(parent, RecoverWithStack(func(ctx context.Context, r interface{}, stack []byte) {
+		assert.Equal(t, "seed", ctx.Value(ctxKey{}))
+	}))
+	seed, cancelSeed := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "seed"))
+	defer cancelSeed()
+
+	values := make(chan interface{}, 2)
+	p.Go(func(ctx context.Context) {
+		values <- ctx.Value(ctxKey{})
+	})
+	p.GoWith(seed, func(ctx context.Context) {
+		values <- ctx.Value(ctxKey{})
+		<-ctx.Done()
+	})
+	p.GoWith(seed, func(ctx context.Context) {
+		panic("recovered with the seed")
+	})
+	assert.ElementsMatch(t, []interface{}{"parent", "seed"}, []interface{}{<-values, <-values})
+	assert.NoError(t, seed.Err())
+	// GoWith的context随Pool一起取消
+	p.Cancel(nil)
+	assert.NoError(t, p.Wait())
+}
+
+func TestPoolGoWithDropped(t *testing.T) {
+	p := NewPool(context.Background(), Size(1))
+	assert.True(t, p.GoWith(context.Background(), func(ctx context.Context) { <-ctx.Done() }))
+	p.Cancel(nil)
+	// Pool已满且已停止，goroutine被丢弃
+	assert.False(t, p.GoWith(context.Background(), func(ctx context.Context) { t.Error("dropped goroutine is run") }))
+	assert.NoError(t, p.Wait())
+}
+
+func TestPoolClosed(t *testing.T) {
+	p := NewPool(context.Background())
+	assert.False(t, p.Closed())
+	assert.True(t, p.TryGo(func(ctx context.Context) {}))
+	assert.NoError(t, p.Wait())
+	assert.True(t, p.Closed())
+
+	var ran int32
+	assert.NotPanics(t, func() {
+		p.Go(func(ctx context.Context) { atomic.AddInt32(&ran, 1) })
+		assert.False(t, p.TryGo(func(ctx context.Context) { atomic.AddInt32(&ran, 1) }))
+	})
+	assert.NoError(t, p.Wait())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&ran))
+}
+
+func TestPoolDrain(t *testing.T) {
+	p := NewPool(context.Background(), Size(1))
+	release := make(chan struct{})
+	p.Go(func(ctx context.Context) { <-release })
+	// 常驻的goroutine在Drained上返回
+	assert.True(t, p.TryGoUnbounded(func(ctx context.Context) { <-p.Drained() }))
+	blocked := make(chan bool)
+	go func() {
+		// Pool已满，Drain后不再等待
+		blocked <- p.TryGo(func(ctx context.Context) {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.Drain()
+	p.Drain()
+	assert.False(t, <-blocked)
+	assert.True(t, p.Closed())
+	assert.False(t, p.TryGo(func(ctx context.Context) {}))
+	// 运行中的goroutine不被取消
+	assert.NoError(t, p.ctx.Err())
+	close(release)
+	assert.NoError(t, p.WaitTimeout(time.Second))
+	assert.Equal(t, 0, p.Running())
+}
+
+func TestPoolTryGoUnbounded(t *testing.T) {
+	p := NewPool(context.Background(), Size(1))
+	done := make(chan struct{})
+	assert.True(t, p.TryGoUnbounded(func(ctx context.Context) {
+		// 常驻的goroutine不占用名额
+		p.Go(func(ctx context.Context) { close(done) })
+		<-ctx.Done()
+	}))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("bounded goroutine is not started")
+	}
+	assert.Equal(t, 1, p.Running())
+	p.Cancel(nil)
+	assert.NoError(t, p.Wait())
+	assert.False(t, p.TryGoUnbounded(func(ctx context.Context) {}))
+}
+
+func TestPoolWaitRace(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		p := NewPool(context.Background())
+		var started, finished int32
+		first, spawned := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(spawned)
+			for n := 0; n < 1000 && p.TryGo(func(ctx context.Context) { atomic.AddInt32(&finished, 1) }); n++ {
+				if atomic.AddInt32(&started, 1) == 1 {
+					close(first)
+				}
+			}
+		}()
+		<-first
+		// 启动goroutine的同时Wait
+		assert.NoError(t, p.Wait())
+		waited := atomic.LoadInt32(&finished)
+		<-spawned
+		// Wait返回后没有goroutine再启动或运行
+		assert.Equal(t, atomic.LoadInt32(&started), waited)
+		assert.Equal(t, waited, atomic.LoadInt32(&finished))
+	}
+}
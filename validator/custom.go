@@ -6,6 +6,8 @@ import (
 	"github.com/go-playground/validator/v10"
 )
 
+var mobileCompile = regexp.MustCompile(`^1[3-9]\d{9}$`)
+
 var sortCompile = regexp.MustCompile(`^[a-z][a-z_]{0,30}[a-z](\s(asc|ASC|desc|DESC))?(,[a-z][a-z_]{0,30}[a-z](\s(asc|ASC|desc|DESC))?)*$`)
 
 func Sort(f1 validator.FieldLevel) bool {
@@ -15,3 +17,12 @@ func Sort(f1 validator.FieldLevel) bool {
 	}
 	return sortCompile.MatchString(valid)
 }
+
+// Mobile validates a mainland China mobile phone number
+func Mobile(f1 validator.FieldLevel) bool {
+	valid, ok := f1.Field().Interface().(string)
+	if !ok {
+		return false
+	}
+	return mobileCompile.MatchString(valid)
+}
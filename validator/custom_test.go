@@ -92,3 +92,51 @@ func TestSort(t *testing.T) {
 		})
 	}
 }
+
+type structMobile struct {
+	Phone string `binding:"mobile"`
+	Alias string `binding:"omitempty,phone"`
+}
+
+func TestMobile(t *testing.T) {
+	v := NewValidator()
+	assert.Nil(t, v.RegisterValidation("mobile", Mobile))
+	v.RegisterAlias("phone", "mobile")
+
+	tests := []struct {
+		name    string
+		input   structMobile
+		wantErr bool
+	}{
+		{
+			name:    "ok",
+			input:   structMobile{Phone: "13812345678"},
+			wantErr: false,
+		},
+		{
+			name:    "short",
+			input:   structMobile{Phone: "1381234567"},
+			wantErr: true,
+		},
+		{
+			name:    "prefix",
+			input:   structMobile{Phone: "12812345678"},
+			wantErr: true,
+		},
+		{
+			name:    "alias",
+			input:   structMobile{Phone: "13812345678", Alias: "abc"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.ValidateStruct(tt.input)
+			if tt.wantErr {
+				assert.NotNil(t, err, "err:%v", err)
+				return
+			}
+			assert.Nil(t, err, "err:%v", err)
+		})
+	}
+}
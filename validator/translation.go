@@ -0,0 +1,72 @@
+package validator
+
+import (
+	"fmt"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+	zhtranslations "github.com/go-playground/validator/v10/translations/zh"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+const (
+	LocaleZH = "zh"
+	LocaleEN = "en"
+)
+
+var localeTranslations = map[string]struct {
+	locale   func() locales.Translator
+	register func(*validator.Validate, ut.Translator) error
+}{
+	LocaleZH: {locale: zh.New, register: zhtranslations.RegisterDefaultTranslations},
+	LocaleEN: {locale: en.New, register: entranslations.RegisterDefaultTranslations},
+}
+
+// RegisterTranslations registers the messages of locale, LocaleZH or LocaleEN,
+// the first registered locale is used by ValidateStruct
+func (v *defaultValidator) RegisterTranslations(locale string) error {
+	t, ok := localeTranslations[locale]
+	if !ok {
+		return fmt.Errorf("locale %s is not supported", locale)
+	}
+	if v.uni == nil {
+		v.uni = ut.New(t.locale())
+	} else if err := v.uni.AddTranslator(t.locale(), true); err != nil {
+		return err
+	}
+	translator, _ := v.uni.GetTranslator(locale)
+	if err := t.register(v.Validate, translator); err != nil {
+		return err
+	}
+	if v.translator == nil {
+		v.translator = translator
+	}
+	return nil
+}
+
+// TranslateLocale translates the FieldError in err returned by ValidateStruct to locale,
+// the locale used by ValidateStruct is used if locale isn't registered
+func (v *defaultValidator) TranslateLocale(err error, locale string) error {
+	translator := v.translator
+	if v.uni != nil {
+		if t, found := v.uni.GetTranslator(locale); found {
+			translator = t
+		}
+	}
+	var errs error
+	for _, e := range multierr.Errors(err) {
+		var fe FieldError
+		if !errors.As(e, &fe) || fe.raw == nil {
+			errs = multierr.Append(errs, e)
+			continue
+		}
+		fe.Message = fe.raw.Translate(translator)
+		errs = multierr.Append(errs, fe)
+	}
+	return errs
+}
@@ -2,40 +2,143 @@ package validator
 
 import (
 	"reflect"
+	"strings"
+	"sync"
 
-	"github.com/go-playground/locales/zh"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
-	translations "github.com/go-playground/validator/v10/translations/zh"
 	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 )
 
+// Validator also implements gin binding.StructValidator,
+// so the tags registered on it are honored by both async payloads and gin binding
 type Validator interface {
 	ValidateStruct(obj interface{}) error
+	ValidateStructDetailed(obj interface{}) ([]FieldError, error)
 	Engine() interface{}
+	RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error
+	RegisterAlias(alias, tags string)
+	// RegisterTagNameFunc sets how the field names in the errors are got, see WithTagName
+	RegisterTagNameFunc(fn validator.TagNameFunc)
+	RegisterTranslations(locale string) error
+	TranslateLocale(err error, locale string) error
 }
 
-// New validator
-func New() (*defaultValidator, error) {
-	v := &defaultValidator{Validate: validator.New()}
-	v.Validate.SetTagName("binding")
-	v.translator, _ = ut.New(zh.New()).GetTranslator("zh")
-	if err := translations.RegisterDefaultTranslations(v.Validate, v.translator); err != nil {
+// FieldError is the detail of a field failing validation, it can be the result of e.ErrInvalidParam
+type FieldError struct {
+	Namespace string `json:"namespace"`
+	Field     string `json:"field"`
+	Tag       string `json:"tag"`
+	Param     string `json:"param,omitempty"`
+	Message   string `json:"message"`
+
+	raw validator.FieldError
+}
+
+func (f FieldError) Error() string {
+	return f.Message
+}
+
+// FieldErrors return the FieldError in err returned by ValidateStruct,
+// the untranslated validator.ValidationErrors, e.g. of Var, are converted with their English message
+func FieldErrors(err error) []FieldError {
+	var list []FieldError
+	for _, e := range multierr.Errors(err) {
+		var fe FieldError
+		if errors.As(e, &fe) {
+			list = append(list, fe)
+			continue
+		}
+		var vErrs validator.ValidationErrors
+		if errors.As(e, &vErrs) {
+			for _, raw := range vErrs {
+				list = append(list, FieldError{
+					Namespace: raw.Namespace(),
+					Field:     raw.Field(),
+					Tag:       raw.Tag(),
+					Param:     raw.Param(),
+					Message:   raw.Error(),
+					raw:       raw,
+				})
+			}
+		}
+	}
+	return list
+}
+
+type option struct {
+	tagName    string
+	taggedOnly bool
+}
+
+// Option configures the validator created by New and NewValidator
+type Option func(*option)
+
+// WithTagName names the fields in the errors by the struct tag tagName, e.g. json,
+// so that they match what the client sent. A field without the tag keeps its Go name.
+func WithTagName(tagName string) Option {
+	return func(o *option) {
+		o.tagName = tagName
+	}
+}
+
+// WithTaggedOnly validates only the fields carrying the binding tag, a struct field without it
+// is not descended into, so that the nested structs of other packages are left alone.
+func WithTaggedOnly() Option {
+	return func(o *option) {
+		o.taggedOnly = true
+	}
+}
+
+// New validator with zh messages
+func New(opts ...Option) (*defaultValidator, error) {
+	v := newValidator(opts...)
+	if err := v.RegisterTranslations(LocaleZH); err != nil {
 		return nil, err
 	}
 	return v, nil
 }
 
-func NewValidator() Validator {
+func NewValidator(opts ...Option) Validator {
+	return newValidator(opts...)
+}
+
+func newValidator(opts ...Option) *defaultValidator {
+	o := &option{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	v := &defaultValidator{Validate: validator.New()}
-	v.Validate.SetTagName("binding")
+	v.Validate.SetTagName(bindingTag)
+	if o.taggedOnly {
+		v.filters = &sync.Map{}
+	}
+	if o.tagName != "" {
+		v.RegisterTagNameFunc(tagNameFunc(o.tagName))
+	}
 	return v
 }
 
+// tagNameFunc returns the name in the tag tagName, like json:"name,omitempty"
+func tagNameFunc(tagName string) validator.TagNameFunc {
+	return func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get(tagName), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	}
+}
+
+const bindingTag = "binding"
+
 type defaultValidator struct {
 	Validate   *validator.Validate
+	uni        *ut.UniversalTranslator
 	translator ut.Translator
+	// filters 缓存每个类型中需要跳过的字段，nil表示校验全部字段
+	filters *sync.Map
 }
 
 // ValidateStruct receives any kind of type, but only performed struct or pointer to struct type.
@@ -47,22 +150,130 @@ func (v *defaultValidator) ValidateStruct(obj interface{}) error {
 	return v.Translate(err)
 }
 
+// ValidateStructDetailed is ValidateStruct which returns the fields failing validation apart,
+// the error is for the failures which are not about a field
+func (v *defaultValidator) ValidateStructDetailed(obj interface{}) ([]FieldError, error) {
+	var (
+		fieldErrs []FieldError
+		errs      error
+	)
+	for _, err := range multierr.Errors(v.ValidateStruct(obj)) {
+		var fe FieldError
+		if errors.As(err, &fe) {
+			fieldErrs = append(fieldErrs, fe)
+			continue
+		}
+		errs = multierr.Append(errs, err)
+	}
+	return fieldErrs, errs
+}
+
 // Translate receives struct type
 func (v *defaultValidator) Translate(err error) error {
-	var vErrs validator.ValidationErrors
-	if !errors.As(err, &vErrs) {
-		return err
-	}
 	var errs error
-	for _, s := range vErrs.Translate(v.translator) {
-		errs = multierr.Append(errs, errors.New(s))
+	for _, e := range multierr.Errors(err) {
+		var vErrs validator.ValidationErrors
+		if !errors.As(e, &vErrs) {
+			errs = multierr.Append(errs, e)
+			continue
+		}
+		for _, fe := range vErrs {
+			errs = multierr.Append(errs, FieldError{
+				Namespace: fe.Namespace(),
+				Field:     fe.Field(),
+				Tag:       fe.Tag(),
+				Param:     fe.Param(),
+				Message:   fe.Translate(v.translator),
+				raw:       fe,
+			})
+		}
 	}
 	return errs
 }
 
 // validateStruct receives struct type
 func (v *defaultValidator) validateStruct(obj interface{}) error {
-	return v.Validate.Struct(obj)
+	if v.filters == nil {
+		return v.Validate.Struct(obj)
+	}
+	typ := reflect.TypeOf(obj)
+	skip, ok := v.filters.Load(typ)
+	if !ok {
+		skip, _ = v.filters.LoadOrStore(typ, untaggedFields(typ))
+	}
+	return v.Validate.StructFiltered(obj, skip.(skipFields).filter)
+}
+
+// skipFields are the namespaces of the untagged struct fields, like Type.Field.Nested
+type skipFields map[string]struct{}
+
+func (s skipFields) filter(ns []byte) bool {
+	if len(s) == 0 {
+		return false
+	}
+	// dive的元素带有下标，如Type.Items[0].Nested
+	path := make([]byte, 0, len(ns))
+	for i := 0; i < len(ns); i++ {
+		if ns[i] == '[' {
+			for i < len(ns) && ns[i] != ']' {
+				i++
+			}
+			continue
+		}
+		if ns[i] == '.' {
+			if _, ok := s[string(path)]; ok {
+				return true
+			}
+		}
+		path = append(path, ns[i])
+	}
+	_, ok := s[string(path)]
+	return ok
+}
+
+// untaggedFields collects the struct fields of typ without the binding tag
+func untaggedFields(typ reflect.Type) skipFields {
+	skip := skipFields{}
+	collectUntagged(typ, typ.Name(), skip, map[reflect.Type]bool{})
+	return skip
+}
+
+func collectUntagged(typ reflect.Type, prefix string, skip skipFields, visiting map[reflect.Type]bool) {
+	if visiting[typ] {
+		return
+	}
+	visiting[typ] = true
+	defer delete(visiting, typ)
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.Anonymous && field.PkgPath != "" {
+			continue
+		}
+		elem := structElem(field.Type)
+		if elem == nil {
+			continue
+		}
+		ns := prefix + "." + field.Name
+		if _, ok := field.Tag.Lookup(bindingTag); !ok {
+			skip[ns] = struct{}{}
+			continue
+		}
+		collectUntagged(elem, ns, skip, visiting)
+	}
+}
+
+// structElem returns the struct type of t, following pointers and the elements of slices and maps
+func structElem(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() { // nolint:exhaustive
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		case reflect.Struct:
+			return t
+		default:
+			return nil
+		}
+	}
 }
 
 // Engine returns the underlying validator engine which powers the default
@@ -80,14 +291,14 @@ func (v *defaultValidator) defaultValidateStruct(obj interface{}) error {
 	value := reflect.ValueOf(obj)
 	switch value.Kind() { // nolint:exhaustive
 	case reflect.Ptr:
-		return v.ValidateStruct(value.Elem().Interface())
+		return v.defaultValidateStruct(value.Elem().Interface())
 	case reflect.Struct:
 		return v.validateStruct(obj)
 	case reflect.Slice, reflect.Array:
 		count := value.Len()
 		var errs error
 		for i := 0; i < count; i++ {
-			if err := v.ValidateStruct(value.Index(i).Interface()); err != nil {
+			if err := v.defaultValidateStruct(value.Index(i).Interface()); err != nil {
 				errs = multierr.Append(errs, err)
 			}
 		}
@@ -97,12 +308,23 @@ func (v *defaultValidator) defaultValidateStruct(obj interface{}) error {
 	}
 }
 
+// RegisterValidation adds a validation with the given tag, it should be called once at startup
+func (v *defaultValidator) RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
+	return v.Validate.RegisterValidation(tag, fn, callValidationEvenIfNull...)
+}
+
+// RegisterAlias registers a tag standing for tags, e.g. RegisterAlias("iscolor", "hexcolor|rgb|rgba")
+func (v *defaultValidator) RegisterAlias(alias, tags string) {
+	v.Validate.RegisterAlias(alias, tags)
+}
+
+// RegisterTagNameFunc sets how the field names in the errors are got, it should be called once at startup
+func (v *defaultValidator) RegisterTagNameFunc(fn validator.TagNameFunc) {
+	v.Validate.RegisterTagNameFunc(fn)
+}
+
 func RegisterValidation(v Validator, tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
-	validate, ok := v.Engine().(*validator.Validate)
-	if !ok {
-		return nil
-	}
-	return validate.RegisterValidation(tag, fn, callValidationEvenIfNull...)
+	return v.RegisterValidation(tag, fn, callValidationEvenIfNull...)
 }
 
 func Var(v Validator, field interface{}, tag string) error {
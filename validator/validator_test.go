@@ -0,0 +1,123 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type structDetailed struct {
+	Name string `binding:"required"`
+	Age  int    `binding:"gte=1,lte=130"`
+}
+
+func TestValidateStructDetailed(t *testing.T) {
+	translated, err := New()
+	assert.NoError(t, err)
+	testList := []struct {
+		name     string
+		input    interface{}
+		expected []FieldError
+	}{
+		{
+			name:  "ok",
+			input: structDetailed{Name: "a", Age: 1},
+		},
+		{
+			name:  "pointer",
+			input: &structDetailed{Age: 140},
+			expected: []FieldError{
+				{Namespace: "structDetailed.Name", Field: "Name", Tag: "required"},
+				{Namespace: "structDetailed.Age", Field: "Age", Tag: "lte", Param: "130"},
+			},
+		},
+		{
+			name:  "slice",
+			input: []structDetailed{{Name: "a", Age: 1}, {Name: "b"}},
+			expected: []FieldError{
+				{Namespace: "structDetailed.Age", Field: "Age", Tag: "gte", Param: "1"},
+			},
+		},
+	}
+	for _, v := range []Validator{NewValidator(), translated} {
+		for _, data := range testList {
+			t.Run(data.name, func(t *testing.T) {
+				fieldErrs, err := v.ValidateStructDetailed(data.input)
+				assert.NoError(t, err)
+				assert.Len(t, fieldErrs, len(data.expected))
+				for i, fe := range fieldErrs {
+					assert.NotEmpty(t, fe.Message)
+					assert.Equal(t, data.expected[i].Namespace, fe.Namespace)
+					assert.Equal(t, data.expected[i].Field, fe.Field)
+					assert.Equal(t, data.expected[i].Tag, fe.Tag)
+					assert.Equal(t, data.expected[i].Param, fe.Param)
+				}
+				assert.Equal(t, fieldErrs, FieldErrors(v.ValidateStruct(data.input)))
+			})
+		}
+	}
+}
+
+func TestTranslateLocale(t *testing.T) {
+	v := NewValidator()
+	assert.NoError(t, v.RegisterTranslations(LocaleZH))
+	assert.NoError(t, v.RegisterTranslations(LocaleEN))
+	assert.Error(t, v.RegisterTranslations("xx"))
+
+	err := v.ValidateStruct(structDetailed{Age: 1})
+	assert.EqualError(t, err, "Name为必填字段")
+	assert.EqualError(t, v.TranslateLocale(err, LocaleEN), "Name is a required field")
+	assert.EqualError(t, v.TranslateLocale(err, "xx"), "Name为必填字段")
+}
+
+type structTagName struct {
+	Name  string `json:"user_name,omitempty" binding:"required"`
+	Age   int    `json:"-" binding:"gte=1"`
+	Email string `binding:"required,email"`
+}
+
+func TestWithTagName(t *testing.T) {
+	v, err := New(WithTagName("json"))
+	assert.NoError(t, err)
+	fieldErrs, err := v.ValidateStructDetailed(structTagName{})
+	assert.NoError(t, err)
+	if assert.Len(t, fieldErrs, 3) {
+		assert.Equal(t, "structTagName.user_name", fieldErrs[0].Namespace)
+		assert.Equal(t, "user_name", fieldErrs[0].Field)
+		assert.Equal(t, "user_name为必填字段", fieldErrs[0].Message)
+		assert.Equal(t, "Age", fieldErrs[1].Field)
+		assert.Equal(t, "Email", fieldErrs[2].Field)
+	}
+
+	fieldErrs, err = NewValidator().ValidateStructDetailed(structTagName{})
+	assert.NoError(t, err)
+	if assert.Len(t, fieldErrs, 3) {
+		assert.Equal(t, "Name", fieldErrs[0].Field)
+	}
+}
+
+type taggedInner struct {
+	Name string `binding:"required"`
+}
+
+type structTaggedOnly struct {
+	Tagged   taggedInner   `binding:"required"`
+	Untagged taggedInner   // 未打标签的嵌套结构体不会被校验
+	Items    []taggedInner `binding:"dive"`
+	Ptr      *taggedInner
+}
+
+func TestWithTaggedOnly(t *testing.T) {
+	input := structTaggedOnly{Items: []taggedInner{{}}, Ptr: &taggedInner{}}
+	fieldErrs, err := NewValidator().ValidateStructDetailed(input)
+	assert.NoError(t, err)
+	assert.Len(t, fieldErrs, 4)
+
+	fieldErrs, err = NewValidator(WithTaggedOnly()).ValidateStructDetailed(input)
+	assert.NoError(t, err)
+	namespaces := make([]string, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		namespaces = append(namespaces, fe.Namespace)
+	}
+	assert.Equal(t, []string{"structTaggedOnly.Tagged.Name", "structTaggedOnly.Items[0].Name"}, namespaces)
+}